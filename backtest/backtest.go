@@ -0,0 +1,295 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/strategy"
+	"strings"
+	"time"
+)
+
+// Options is the cost model applied to every simulated swap
+type Options struct {
+	Strategies    []config.EntryStrategyConfig
+	FeeBps        float64 // dex fee on each swap
+	SlippageBps   float64 // price impact on each swap, buys pay more and sells receive less
+	NetworkFeeSol float64 // transaction fee of each swap
+}
+
+type Trade struct {
+	Strategy    string
+	Token       string
+	EntryAt     time.Time
+	ExitAt      time.Time
+	EntryPrice  float64 // SOL per token paid, slippage included
+	ExitPrice   float64 // market price of the last exit
+	CostSol     float64
+	ProceedsSol float64
+	PnlSol      float64
+	Return      float64 // PnL relative to the cost
+	ExitReason  string
+}
+
+type Stats struct {
+	Trades         int
+	WinRate        float64
+	AvgReturn      float64
+	TotalPnlSol    float64
+	MaxDrawdownSol float64 // largest drop of the cumulative PnL from its peak
+	Sharpe         float64 // mean over standard deviation of the trade returns, not annualized
+}
+
+type Result struct {
+	Trades []Trade
+	Stats  Stats
+}
+
+type position struct {
+	strategy config.EntryStrategyConfig
+	rules    db.SwapRules
+	trade    Trade
+
+	initialTokens float64
+	tokens        float64
+	peakPrice     float64
+	doneSteps     map[int]bool
+}
+
+type backtester struct {
+	o         Options
+	positions map[string][]*position // by token
+	traded    map[string]bool        // strategy and token pairs that already bought
+	trades    []Trade
+}
+
+// Run replays the market data snapshots in order, opening positions when an entry strategy matches
+// and closing them with its exit rules. Positions still open at the end are closed at their last price.
+// Risk scores are the ones stored on the tokens, which may have been computed after the snapshot.
+func Run(tokens []db.TokenEntity, snapshots []db.MarketDataEntity, o Options) Result {
+	b := &backtester{
+		o:         o,
+		positions: make(map[string][]*position),
+		traded:    make(map[string]bool),
+	}
+
+	byAddress := make(map[string]db.TokenEntity)
+
+	for _, t := range tokens {
+		byAddress[t.ContractAddress] = t
+	}
+
+	last := make(map[string]db.MarketDataEntity)
+
+	for i := range snapshots {
+		m := snapshots[i]
+
+		token, ok := byAddress[m.ContractAddress]
+
+		if !ok || m.PriceNative <= 0 {
+			continue
+		}
+
+		last[m.ContractAddress] = m
+
+		b.evaluateExits(m)
+
+		for _, s := range o.Strategies {
+			b.evaluateEntry(s, token, &m)
+		}
+	}
+
+	for address, positions := range b.positions {
+		for _, p := range positions {
+			b.sell(p, p.tokens, last[address], "end of data")
+		}
+	}
+
+	slices.SortStableFunc(b.trades, func(x, y Trade) int {
+		if c := x.ExitAt.Compare(y.ExitAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(x.Token+x.Strategy, y.Token+y.Strategy)
+	})
+
+	return Result{Trades: b.trades, Stats: computeStats(b.trades)}
+}
+
+func (b *backtester) evaluateEntry(s config.EntryStrategyConfig, token db.TokenEntity, m *db.MarketDataEntity) {
+	key := s.Name + "/" + token.ContractAddress
+
+	if s.QuantitySol <= 0 || b.traded[key] {
+		return
+	}
+
+	if _, ok := strategy.EvaluateEntry(s, token, m, m.Timestamp); !ok {
+		return
+	}
+
+	if s.MaxSolPerToken > 0 && b.committed(token.ContractAddress)+float64(s.QuantitySol) > float64(s.MaxSolPerToken) {
+		return
+	}
+
+	if s.MaxSolTotal > 0 && b.committed("")+float64(s.QuantitySol) > float64(s.MaxSolTotal) {
+		return
+	}
+
+	b.traded[key] = true
+
+	var rules db.SwapRules
+
+	if len(s.ExitRules) > 0 {
+		json.Unmarshal(s.ExitRules, &rules)
+	}
+
+	sol := float64(s.QuantitySol)
+	price := m.PriceNative * (1 + b.o.SlippageBps/10000)
+	tokens := sol * (1 - b.o.FeeBps/10000) / price
+
+	p := &position{
+		strategy:      s,
+		rules:         rules,
+		initialTokens: tokens,
+		tokens:        tokens,
+		peakPrice:     price,
+		doneSteps:     make(map[int]bool),
+		trade: Trade{
+			Strategy:   s.Name,
+			Token:      token.ContractAddress,
+			EntryAt:    m.Timestamp,
+			EntryPrice: price,
+			CostSol:    sol + b.o.NetworkFeeSol,
+		},
+	}
+
+	b.positions[token.ContractAddress] = append(b.positions[token.ContractAddress], p)
+}
+
+// evaluateExits applies the exit rules of every position in the token, the same way the position monitor does
+func (b *backtester) evaluateExits(m db.MarketDataEntity) {
+	var open []*position
+
+	for _, p := range b.positions[m.ContractAddress] {
+		price := m.PriceNative
+		p.peakPrice = max(p.peakPrice, price)
+
+		if step, reason, ok := strategy.EvaluateLadder(p.rules, p.trade.EntryPrice, price, p.doneSteps); ok {
+			p.doneSteps[step] = true
+			b.sell(p, float64(p.rules.Ladder[step].Fraction)*p.initialTokens, m, reason)
+		}
+
+		if p.tokens > 0 {
+			reason, triggered := strategy.EvaluateExit(p.rules, p.trade.EntryPrice, price)
+
+			if !triggered && len(p.doneSteps) >= len(p.rules.Ladder) {
+				reason, triggered = strategy.EvaluateTrailingStop(p.rules, p.peakPrice, price)
+			}
+
+			if triggered {
+				b.sell(p, p.tokens, m, reason)
+			}
+		}
+
+		if p.tokens > 0 {
+			open = append(open, p)
+		}
+	}
+
+	b.positions[m.ContractAddress] = open
+}
+
+// sell exits part of a position, the trade is recorded once nothing is left
+func (b *backtester) sell(p *position, tokens float64, m db.MarketDataEntity, reason string) {
+	tokens = min(tokens, p.tokens)
+	price := m.PriceNative * (1 - b.o.SlippageBps/10000)
+
+	p.tokens -= tokens
+	p.trade.ProceedsSol += tokens*price*(1-b.o.FeeBps/10000) - b.o.NetworkFeeSol
+
+	// ladder fractions may not add up exactly
+	if p.tokens > p.initialTokens*1e-9 {
+		return
+	}
+
+	p.tokens = 0
+	p.trade.ExitAt = m.Timestamp
+	p.trade.ExitPrice = m.PriceNative
+	p.trade.ExitReason = reason
+	p.trade.PnlSol = p.trade.ProceedsSol - p.trade.CostSol
+	p.trade.Return = p.trade.PnlSol / p.trade.CostSol
+
+	b.trades = append(b.trades, p.trade)
+}
+
+// committed returns the SOL in open positions of a token, or of all tokens when token is empty
+func (b *backtester) committed(token string) float64 {
+	var sol float64
+
+	for address, positions := range b.positions {
+		if len(token) > 0 && address != token {
+			continue
+		}
+
+		for _, p := range positions {
+			sol += float64(p.strategy.QuantitySol)
+		}
+	}
+
+	return sol
+}
+
+func computeStats(trades []Trade) Stats {
+	stats := Stats{Trades: len(trades)}
+
+	if len(trades) == 0 {
+		return stats
+	}
+
+	var wins int
+	var sumReturn, cumulative, peak float64
+
+	for _, t := range trades {
+		if t.PnlSol > 0 {
+			wins++
+		}
+
+		sumReturn += t.Return
+		stats.TotalPnlSol += t.PnlSol
+	}
+
+	// trades are sorted by exit, the equity curve follows the order in which they were closed
+	for _, t := range trades {
+		cumulative += t.PnlSol
+		peak = max(peak, cumulative)
+		stats.MaxDrawdownSol = max(stats.MaxDrawdownSol, peak-cumulative)
+	}
+
+	n := float64(len(trades))
+	stats.WinRate = float64(wins) / n
+	stats.AvgReturn = sumReturn / n
+
+	if len(trades) > 1 {
+		var variance float64
+
+		for _, t := range trades {
+			variance += math.Pow(t.Return-stats.AvgReturn, 2)
+		}
+
+		stdDev := math.Sqrt(variance / (n - 1))
+
+		if stdDev > 0 {
+			stats.Sharpe = stats.AvgReturn / stdDev
+		}
+	}
+
+	return stats
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("trades = %d, win rate = %.2f%%, avg return = %.2f%%, total pnl = %.4f SOL, max drawdown = %.4f SOL, sharpe = %.2f",
+		s.Trades, s.WinRate*100, s.AvgReturn*100, s.TotalPnlSol, s.MaxDrawdownSol, s.Sharpe)
+}
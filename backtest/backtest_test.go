@@ -0,0 +1,117 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"solana-bot/config"
+	"solana-bot/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+
+func snapshot(minute int, token string, price float64) db.MarketDataEntity {
+	return db.MarketDataEntity{Timestamp: start.Add(time.Duration(minute) * time.Minute), ContractAddress: token, PriceNative: price}
+}
+
+func tokens(addresses ...string) []db.TokenEntity {
+	var result []db.TokenEntity
+
+	for _, a := range addresses {
+		result = append(result, db.TokenEntity{ContractAddress: a, CreatedAt: start})
+	}
+
+	return result
+}
+
+func options(feeBps, slippageBps, networkFeeSol float64) Options {
+	return Options{
+		Strategies: []config.EntryStrategyConfig{{
+			Name:        "all",
+			QuantitySol: 1,
+			ExitRules:   json.RawMessage(`{"takeProfit": 50, "stopLoss": 20}`),
+		}},
+		FeeBps:        feeBps,
+		SlippageBps:   slippageBps,
+		NetworkFeeSol: networkFeeSol,
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// Four tokens bought with 1 SOL each: a takes profit at +60%, b and c stop out at -30% and -25%, d is still
+// open at +10% when the data ends. The equity curve goes 0.6, 0.3, 0.05, 0.15 SOL.
+func TestRunStats(t *testing.T) {
+	snapshots := []db.MarketDataEntity{
+		snapshot(0, "a", 1), snapshot(0, "b", 1), snapshot(0, "c", 1), snapshot(0, "d", 2),
+		snapshot(0, "unknown", 1),
+		snapshot(1, "a", 1.6), snapshot(1, "b", 0.9),
+		snapshot(2, "b", 0.7),
+		snapshot(3, "c", 0.75),
+		snapshot(4, "d", 2.2),
+	}
+
+	result := Run(tokens("a", "b", "c", "d"), snapshots, options(0, 0, 0))
+
+	want := []struct {
+		token  string
+		pnl    float64
+		reason string
+	}{
+		{"a", 0.6, "take profit"},
+		{"b", -0.3, "stop loss"},
+		{"c", -0.25, "stop loss"},
+		{"d", 0.1, "end of data"},
+	}
+
+	if len(result.Trades) != len(want) {
+		t.Fatalf("got %d trades, want %d: %+v", len(result.Trades), len(want), result.Trades)
+	}
+
+	for i, w := range want {
+		tr := result.Trades[i]
+
+		if tr.Token != w.token || !approx(tr.PnlSol, w.pnl) || !strings.HasPrefix(tr.ExitReason, w.reason) {
+			t.Errorf("trade %d = %s %.4f SOL (%s), want %s %.4f SOL (%s)", i, tr.Token, tr.PnlSol, tr.ExitReason, w.token, w.pnl, w.reason)
+		}
+	}
+
+	s := result.Stats
+
+	if s.Trades != 4 || !approx(s.WinRate, 0.5) {
+		t.Errorf("trades = %d, win rate = %f, want 4 and 0.5", s.Trades, s.WinRate)
+	}
+
+	if !approx(s.TotalPnlSol, 0.15) || !approx(s.AvgReturn, 0.0375) {
+		t.Errorf("total pnl = %f, avg return = %f, want 0.15 and 0.0375", s.TotalPnlSol, s.AvgReturn)
+	}
+
+	if !approx(s.MaxDrawdownSol, 0.55) {
+		t.Errorf("max drawdown = %f, want 0.55", s.MaxDrawdownSol)
+	}
+}
+
+// The dex fee and slippage are paid on both swaps and the network fee on each of them
+func TestRunCosts(t *testing.T) {
+	snapshots := []db.MarketDataEntity{snapshot(0, "a", 1), snapshot(1, "a", 1.6)}
+
+	result := Run(tokens("a"), snapshots, options(100, 100, 0.001))
+
+	if len(result.Trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(result.Trades))
+	}
+
+	tr := result.Trades[0]
+
+	if !approx(tr.EntryPrice, 1.01) || !approx(tr.CostSol, 1.001) {
+		t.Errorf("entry price = %f, cost = %f, want 1.01 and 1.001", tr.EntryPrice, tr.CostSol)
+	}
+
+	if math.Abs(tr.PnlSol-0.535107327) > 1e-6 || result.Stats.WinRate != 1 || result.Stats.MaxDrawdownSol != 0 {
+		t.Errorf("pnl = %f, stats = %+v, want 0.535107 SOL, all won and no drawdown", tr.PnlSol, result.Stats)
+	}
+}
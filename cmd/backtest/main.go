@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"solana-bot/backtest"
+	"solana-bot/config"
+	"solana-bot/db"
+	"text/tabwriter"
+	"time"
+)
+
+// backtest replays the market_data of the sqlite database against the entry strategies of the config,
+// it needs no network access
+func main() {
+	configPath := flag.String("config", "./config.json", "config file with the strategies")
+	dbPath := flag.String("db", "", "sqlite database, defaults to engine.databaseName of the config")
+	name := flag.String("strategy", "", "only run the strategy with this name")
+	feeBps := flag.Float64("fee-bps", 25, "dex fee of each swap in bps")
+	slippageBps := flag.Float64("slippage-bps", 100, "slippage of each swap in bps")
+	networkFee := flag.Float64("network-fee", 0.000005, "transaction fee of each swap in SOL")
+	flag.Parse()
+
+	c := getConfig(*configPath)
+
+	if len(*dbPath) == 0 {
+		*dbPath = c.Engine.DSN
+	}
+
+	var strategies []config.EntryStrategyConfig
+
+	for _, s := range c.Strategies {
+		if len(*name) == 0 || s.Name == *name {
+			strategies = append(strategies, s)
+		}
+	}
+
+	if len(strategies) == 0 {
+		log.Fatal("No strategies to backtest")
+	}
+
+	sc := db.New(*dbPath)
+	defer sc.Close()
+
+	result := backtest.Run(sc.GetAllTokens(), sc.GetMarketDataSeries(), backtest.Options{
+		Strategies:    strategies,
+		FeeBps:        *feeBps,
+		SlippageBps:   *slippageBps,
+		NetworkFeeSol: *networkFee,
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "strategy\ttoken\tentry\texit\tentry price\texit price\tpnl (SOL)\treturn\treason")
+
+	for _, t := range result.Trades {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%g\t%g\t%.4f\t%.2f%%\t%s\n", t.Strategy, t.Token, t.EntryAt.Format(time.DateTime),
+			t.ExitAt.Format(time.DateTime), t.EntryPrice, t.ExitPrice, t.PnlSol, t.Return*100, t.ExitReason)
+	}
+
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println(result.Stats)
+}
+
+func getConfig(path string) *config.Config {
+	var c config.Config
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		log.Fatal("Failed to open config file", err)
+	}
+
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&c)
+
+	if err != nil {
+		log.Fatal("Failed to decode config file", err)
+	}
+
+	return &c
+}
@@ -1,10 +1,44 @@
 package config
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+type ReconnectConfig struct {
+	InitialBackoffMs    int `json:"initialBackoffMs"`
+	MaxBackoffMs        int `json:"maxBackoffMs"`
+	MaxRetries          int `json:"maxRetries"` // consecutive failed attempts before giving up, 0 retries forever
+	PingIntervalSeconds int `json:"pingIntervalSeconds"`
+}
+
+type CaptureConfig struct {
+	Dir       string `json:"dir"` // capture is disabled when empty
+	MaxFileMB int    `json:"maxFileMB"`
+}
+
 type HeliusConfig struct {
-	ApiKey       string `json:"apiKey"`
-	RpcUrl       string `json:"rpcUrl"`
-	WebSocketUrl string `json:"wsUrl"`
-	BaseApiUrl   string `json:"baseApiUrl"`
+	ApiKey       string          `json:"apiKey"`
+	RpcUrl       string          `json:"rpcUrl"`
+	WebSocketUrl string          `json:"wsUrl"`
+	BaseApiUrl   string          `json:"baseApiUrl"`
+	Reconnect    ReconnectConfig `json:"reconnect"`
+	Capture      CaptureConfig   `json:"capture"`
+}
+
+type RpcEndpointConfig struct {
+	Url    string `json:"url"`
+	Weight int    `json:"weight"` // higher weights are preferred at equal latency
+}
+
+type RpcConfig struct {
+	Endpoints          []RpcEndpointConfig `json:"endpoints"` // falls back to the helius rpc url when empty
+	TimeoutMs          int                 `json:"timeoutMs"`
+	HealthCheckSeconds int                 `json:"healthCheckSeconds"`
 }
 
 type DexScreenerConfig struct {
@@ -22,6 +56,144 @@ type JupiterConfig struct {
 	SlippageBps int    `json:"slippageBps"`
 }
 
+// AccountRules are the instruction account indexes of the pool creation, unset fields are not extracted.
+// When the base mint turns out to be a quote mint (e.g. SOL) base and quote are swapped.
+type AccountRules struct {
+	BaseMint  *int `json:"baseMint"`
+	QuoteMint *int `json:"quoteMint"`
+	Pool      *int `json:"pool"`
+	LpMint    *int `json:"lpMint"`
+	Creator   *int `json:"creator"`
+}
+
+// DetectorConfig describes one kind of launch event: the program that emits it, the log line
+// that identifies it and how to read the pool creation instruction
+type DetectorConfig struct {
+	Name       string `json:"name"`
+	ProgramId  string `json:"programId"`
+	LogPattern string `json:"logPattern"`
+
+	// hex encoded prefix of the instruction data (tag or anchor discriminator), empty matches any instruction
+	DataPrefix string       `json:"dataPrefix"`
+	Accounts   AccountRules `json:"accounts"`
+}
+
+// PaperConfig simulates trades against real quotes, fills are the quoted output minus the slippage
+type PaperConfig struct {
+	Enabled     bool    `json:"enabled"`
+	StartingSol float64 `json:"startingSol"` // balance of the simulated wallet when the paper ledger is empty
+	SlippageBps int     `json:"slippageBps"`
+	PriorityFee uint64  `json:"priorityFee"` // lamports charged on top of the network fee
+}
+
+// PriorityFeeConfig sets the compute unit price of swap transactions, an empty mode leaves it to jupiter's default
+type PriorityFeeConfig struct {
+	Mode             string  `json:"mode"`             // fixed, auto or percentile
+	MicroLamports    uint64  `json:"microLamports"`    // fixed compute unit price, also the fallback when no percentile estimate is available
+	MaxLamports      uint64  `json:"maxLamports"`      // auto, cap of jupiter's priority fee estimate
+	PriorityLevel    string  `json:"priorityLevel"`    // auto, medium, high or veryHigh (default)
+	Percentile       float64 `json:"percentile"`       // percentile of the recent fees paid to write-lock the pool accounts (default 75)
+	MaxMicroLamports uint64  `json:"maxMicroLamports"` // cap of the percentile estimate, 0 is uncapped
+}
+
+// BundleConfig sends swaps as a bundle with a tip transfer to a block engine
+type BundleConfig struct {
+	Url                  string `json:"url"` // JSON-RPC bundle endpoint of the block engine
+	TipAccount           string `json:"tipAccount"`
+	TipLamports          uint64 `json:"tipLamports"`
+	TimeoutMs            int    `json:"timeoutMs"`
+	StatusTimeoutSeconds int    `json:"statusTimeoutSeconds"` // how long getBundleStatuses is polled after sending
+}
+
+// Validate checks the settings a bundle can not be sent without
+func (c *BundleConfig) Validate() error {
+	u, err := url.ParseRequestURI(c.Url)
+
+	if err != nil || len(u.Host) == 0 {
+		return fmt.Errorf("bundle.url %q is not a valid url", c.Url)
+	}
+
+	_, err = solana.PublicKeyFromBase58(c.TipAccount)
+
+	if err != nil {
+		return fmt.Errorf("bundle.tipAccount %q is not a valid account: %w", c.TipAccount, err)
+	}
+
+	// the block engine drops bundles without a tip
+	if c.TipLamports == 0 {
+		return errors.New("bundle.tipLamports must be above 0")
+	}
+
+	return nil
+}
+
+// SimulationConfig checks every signed swap with simulateTransaction before sending it
+type SimulationConfig struct {
+	Disabled        bool `json:"disabled"`
+	MaxDeviationBps int  `json:"maxDeviationBps"` // simulated output below the quote by more than this aborts the swap, defaults to jupiter.slippageBps
+}
+
+type TraderConfig struct {
+	ConfirmationPollMs         int `json:"confirmationPollMs"`
+	ConfirmationTimeoutSeconds int `json:"confirmationTimeoutSeconds"` // only used when the blockhash expiry is unknown
+	MonitorIntervalSeconds     int `json:"monitorIntervalSeconds"`     // how often open positions are checked against their exit rules
+	EntryIntervalSeconds       int `json:"entryIntervalSeconds"`       // how often new tokens are checked against the entry strategies
+	SnapshotIntervalMinutes    int `json:"snapshotIntervalMinutes"`    // how often the portfolio is written to portfolio_snapshots
+	MaxAttempts                int `json:"maxAttempts"`                // executions of an order before it fails for good
+	RetryBackoffSeconds        int `json:"retryBackoffSeconds"`        // wait before the second attempt, doubled for every further attempt
+	LeaseSeconds               int `json:"leaseSeconds"`               // how long an order stays claimed without a heartbeat, after a crash other processes take over
+
+	Paper       PaperConfig       `json:"paper"`
+	PriorityFee PriorityFeeConfig `json:"priorityFee"` // orders without their own fee strategy
+	Sender      string            `json:"sender"`      // rpc (default) or bundle
+	Bundle      BundleConfig      `json:"bundle"`
+	Simulation  SimulationConfig  `json:"simulation"`
+}
+
+// RiskConfig sets the thresholds of the rug-risk checks, the score is the weighted share of passed checks (0-100)
+type RiskConfig struct {
+	MinScore           float64  `json:"minScore"`           // buy orders for tokens scoring below are rejected, 0 disables
+	MaxTopHoldersPct   float64  `json:"maxTopHoldersPct"`   // share of the supply held by the 10 largest holders
+	MinLpLockedPct     float64  `json:"minLpLockedPct"`     // share of the LP supply burned or held by a locker
+	MaxTransferFeeBps  int      `json:"maxTransferFeeBps"`  // token-2022 transfer fee
+	MaxCreatorLaunches int      `json:"maxCreatorLaunches"` // tokens previously launched by the same creator
+	LpLockers          []string `json:"lpLockers"`          // owners whose LP tokens count as locked, in addition to the incinerator
+	ExcludedHolders    []string `json:"excludedHolders"`    // owners left out of the holder concentration, e.g. pool authorities
+	BatchSize          int      `json:"batchSize"`
+}
+
+// EntryStrategyConfig buys newly detected tokens whose latest market data passes every filter, zero values disable a filter
+type EntryStrategyConfig struct {
+	Name      string   `json:"name"`
+	Detectors []string `json:"detectors"` // only tokens found by these detectors, empty matches all
+
+	MinLiquidityUsd float64 `json:"minLiquidityUsd"`
+	MinMarketCap    float64 `json:"minMarketCap"`
+	MaxMarketCap    float64 `json:"maxMarketCap"`
+	MinBuySellRatio float64 `json:"minBuySellRatio"` // dexscreener buys / sells of the last 5 minutes
+	MinAgeMinutes   int     `json:"minAgeMinutes"`   // since the pair was created
+	MaxAgeMinutes   int     `json:"maxAgeMinutes"`
+	MinRiskScore    float64 `json:"minRiskScore"`
+
+	QuantitySol    float32         `json:"quantitySol"`    // size of every buy
+	MaxSolPerToken float32         `json:"maxSolPerToken"` // open and pending buys of a single token, across strategies
+	MaxSolTotal    float32         `json:"maxSolTotal"`    // open and pending buys of all tokens, across strategies
+	ExitRules      json.RawMessage `json:"exitRules"`      // rules of the buy orders, see db.SwapRules
+
+	PriorityFee *PriorityFeeConfig `json:"priorityFee"` // fee strategy of the buys and their exits, falls back to trader.priorityFee
+}
+
+// RiskManagerConfig limits what the trader may spend, zero values disable a limit. Only buys are
+// limited, sells reduce the exposure and always go through.
+type RiskManagerConfig struct {
+	MaxSolPerTrade   float64 `json:"maxSolPerTrade"`
+	MaxOpenPositions int     `json:"maxOpenPositions"`
+	MaxSolPerToken   float64 `json:"maxSolPerToken"`   // cost basis of the open position in a token
+	MaxDailyLossSol  float64 `json:"maxDailyLossSol"`  // realized loss since midnight UTC that trips the kill switch
+	MinSolReserve    float64 `json:"minSolReserve"`    // SOL that must remain for fees after a buy
+	MaxTradesPerHour int     `json:"maxTradesPerHour"` // sent swaps, buys and sells
+}
+
 type Config struct {
 	LiquidityPool struct {
 		RaydiumProgramId string `json:"raydiumProgramId"`
@@ -34,11 +206,16 @@ type Config struct {
 		UsdcMint   string `json:"usdcMint"`
 	} `json:"solana"`
 
+	Detectors []DetectorConfig `json:"detectors"`
+
 	Helius HeliusConfig `json:"helius"`
 
+	Rpc RpcConfig `json:"rpc"`
+
 	Engine struct {
 		DSN          string `json:"databaseName"`
 		LogBatchSize int    `json:"processLogBatchSize"`
+		TxSource     string `json:"txSource"` // enhanced (helius transactions API, default) or rpc (getTransaction)
 
 		RefreshTokenMetadata struct {
 			BatchSize        int `json:"batchSize"` // this is a dexscreener limitation
@@ -54,6 +231,18 @@ type Config struct {
 			MinMarketCap     int `json:"minMarketCap"`
 			FrequencySeconds int `json:"frequencySeconds"`
 		} `json:"refreshTopTokens"`
+
+		EventSource struct {
+			Type                string  `json:"type"` // websocket (default), polling or file
+			PollIntervalSeconds int     `json:"pollIntervalSeconds"`
+			File                string  `json:"file"`
+			ReplaySpeed         float64 `json:"replaySpeed"` // 0 replays as fast as possible, 1 at the captured pace, 10 ten times faster
+		} `json:"eventSource"`
+
+		Backfill struct {
+			PageSize int `json:"pageSize"` // getSignaturesForAddress limit, at most 1000
+			MaxPages int `json:"maxPages"`
+		} `json:"backfill"`
 	} `json:"engine"`
 
 	DexScreener DexScreenerConfig `json:"dexscreener"`
@@ -61,4 +250,62 @@ type Config struct {
 	Wallet WalletConfig `json:"wallet"`
 
 	Jupiter JupiterConfig `json:"jupiter"`
+
+	Trader TraderConfig `json:"trader"`
+
+	Risk RiskConfig `json:"risk"`
+
+	Strategies []EntryStrategyConfig `json:"strategies"`
+
+	RiskManager RiskManagerConfig `json:"riskManager"`
+}
+
+// Validate checks the trader settings that would only fail once an order is executed
+func (c *TraderConfig) Validate() error {
+	if c.Sender == "bundle" {
+		return c.Bundle.Validate()
+	}
+
+	return nil
+}
+
+// GetDetectors returns the configured detectors, falling back to the
+// single raydium initialize2 detector described by liquidityPool
+func (c *Config) GetDetectors() []DetectorConfig {
+	if len(c.Detectors) > 0 {
+		return c.Detectors
+	}
+
+	index := func(i int) *int { return &i }
+
+	return []DetectorConfig{
+		{
+			Name:       "raydium-amm-v4",
+			ProgramId:  c.LiquidityPool.RaydiumProgramId,
+			LogPattern: c.LiquidityPool.MigrationMessage,
+			DataPrefix: "01", // initialize2 instruction tag
+			Accounts: AccountRules{
+				Pool:      index(4),
+				LpMint:    index(7),
+				BaseMint:  index(8),
+				QuoteMint: index(9),
+				Creator:   index(17),
+			},
+		},
+	}
+}
+
+// GetDetectorProgramIds returns the distinct program ids of all detectors
+func (c *Config) GetDetectorProgramIds() []string {
+	var programIds []string
+	seen := make(map[string]bool)
+
+	for _, d := range c.GetDetectors() {
+		if !seen[d.ProgramId] {
+			seen[d.ProgramId] = true
+			programIds = append(programIds, d.ProgramId)
+		}
+	}
+
+	return programIds
 }
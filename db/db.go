@@ -3,14 +3,16 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"solana-bot/dexscreener"
 	"solana-bot/utils"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SqlClient struct {
@@ -21,11 +23,12 @@ func (s *SqlClient) Close() {
 	s.db.Close()
 }
 
-func (s *SqlClient) InsertLog(signature string) {
+func (s *SqlClient) InsertLog(signature string, detector string) {
 
-	query := `insert into rpc_logs(signature) values(?)`
+	// the same signature can arrive from the live stream and from a backfill
+	query := `insert into rpc_logs(signature, detector) select ?, ? where not exists (select 1 from rpc_logs where signature = ?)`
 
-	_, err := s.db.Exec(query, signature)
+	_, err := s.db.Exec(query, signature, detector, signature)
 
 	if err != nil {
 		log.Println("InsertLog:", err)
@@ -35,19 +38,29 @@ func (s *SqlClient) InsertLog(signature string) {
 
 }
 
-func (s *SqlClient) InsertNewToken(contractAddress string, signature string) {
+// ErrDuplicateToken means the token was inserted already, by an earlier log of the same pool
+var ErrDuplicateToken = errors.New("token already exists")
 
-	_, err := s.db.Exec(`insert into tokens("contractAddress") values(?)`, contractAddress)
+func (s *SqlClient) InsertNewToken(t TokenEntity, signature string) error {
 
-	if err != nil {
-		log.Println("InsertNewTokens:", err)
+	query := `insert into tokens("contractAddress", "detector", "poolAddress", "lpMint", "quoteMint", "creator") values(?, ?, ?, ?, ?, ?)`
 
-		return
+	_, err := s.db.Exec(query, t.ContractAddress, t.Detector, t.PoolAddress, t.LpMint, t.QuoteMint, t.Creator)
+
+	var sqliteErr sqlite3.Error
+
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return fmt.Errorf("InsertNewToken: %s: %w", t.ContractAddress, ErrDuplicateToken)
+	}
+
+	if err != nil {
+		return fmt.Errorf("InsertNewToken: %w", err)
 	}
 
 	// mark event that corresponds to this signature as processed
 	s.UpdateLogEventAsProcessed(signature)
 
+	return nil
 }
 
 func (s *SqlClient) UpdateLogEventAsProcessed(signature string) {
@@ -61,6 +74,52 @@ func (s *SqlClient) UpdateLogEventAsProcessed(signature string) {
 
 }
 
+// SkipLogEvent marks a log that will never yield a token as processed, with the reason
+func (s *SqlClient) SkipLogEvent(signature string, reason string) {
+	_, err := s.db.Exec(`update rpc_logs set "processedAt" = ?, skipReason = ? where signature = ? and "processedAt" is null`, time.Now(), reason, signature)
+
+	if err != nil {
+		log.Println("SkipLogEvent:", err)
+	}
+}
+
+// RetryLogEvents counts a failed attempt for logs whose transaction was not found,
+// those that reached maxAttempts are skipped
+func (s *SqlClient) RetryLogEvents(signatures []string, maxAttempts int) {
+	if len(signatures) == 0 {
+		return
+	}
+
+	placeholders := strings.Join(makePlaceHolders(len(signatures)), ",")
+
+	query := fmt.Sprintf(`update rpc_logs set attempts = attempts + 1 where "processedAt" is null and signature in (%s)`, placeholders)
+
+	_, err := s.db.Exec(query, toInterfaceSlice(signatures)...)
+
+	if err != nil {
+		log.Println("RetryLogEvents:", err)
+
+		return
+	}
+
+	query = fmt.Sprintf(`update rpc_logs set "processedAt" = ?, skipReason = ? where "processedAt" is null and attempts >= ? and signature in (%s)`, placeholders)
+
+	params := []interface{}{time.Now(), "transaction not found", maxAttempts}
+	params = append(params, toInterfaceSlice(signatures)...)
+
+	result, err := s.db.Exec(query, params...)
+
+	if err != nil {
+		log.Println("RetryLogEvents:", err)
+
+		return
+	}
+
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Printf("RetryLogEvents: Skipped %d logs after %d attempts \n", count, maxAttempts)
+	}
+}
+
 func toInterfaceSlice(values []string) []interface{} {
 	var result []interface{}
 	for _, name := range values {
@@ -100,7 +159,8 @@ func (s *SqlClient) UpdateTokensAsProcessed(addresses []string) {
 
 func (s *SqlClient) DeleteLogs() {
 
-	query := `delete from rpc_logs  where "processedAt" is not null`
+	// skipped logs are kept for a day so their reason can be looked at
+	query := `delete from rpc_logs  where "processedAt" is not null and (skipReason is null or createdAt < datetime('now', '-1 day'))`
 
 	result, err := s.db.Exec(query)
 
@@ -128,7 +188,8 @@ func (s *SqlClient) GetUnProcessedLogs(limit uint) []RpcLogEntity {
 
 	var rpcLogs []RpcLogEntity
 
-	query := `select id, signature, createdAt, processedAt from  rpc_logs r where r."processedAt" is null LIMIT ?`
+	// logs that were retried go last, so new logs are not held up by transactions that are not found
+	query := `select id, signature, detector, createdAt, processedAt from  rpc_logs r where r."processedAt" is null order by r.attempts, r.id LIMIT ?`
 
 	rows, err := s.db.Query(query, limit)
 
@@ -141,7 +202,7 @@ func (s *SqlClient) GetUnProcessedLogs(limit uint) []RpcLogEntity {
 	for rows.Next() {
 
 		var rpcLog RpcLogEntity
-		err = rows.Scan(&rpcLog.Id, &rpcLog.Signature, &rpcLog.CreatedAt, &rpcLog.ProcessedAt)
+		err = rows.Scan(&rpcLog.Id, &rpcLog.Signature, &rpcLog.Detector, &rpcLog.CreatedAt, &rpcLog.ProcessedAt)
 
 		if err != nil {
 			log.Println("GetUnProcessedLogs:", err)
@@ -176,6 +237,27 @@ func (s *SqlClient) GetScamTokens(minMarketCap int, minAgeHours int) []string {
 	return addresses
 }
 
+// GetRiskyTokens returns the tokens whose risk score is below minScore
+func (s *SqlClient) GetRiskyTokens(minScore float64) []string {
+	var addresses []string
+
+	rows, err := s.db.Query(`select t.contractAddress from tokens t where t.riskScore < ?`, minScore)
+
+	if err != nil {
+		log.Println("GetRiskyTokens:", err)
+
+		return addresses
+	}
+
+	for rows.Next() {
+		var contractAddress string
+		rows.Scan(&contractAddress)
+		addresses = append(addresses, contractAddress)
+	}
+
+	return addresses
+}
+
 func (s *SqlClient) DeleteTokens(addresses []string) {
 	placeholders := makePlaceHolders(len(addresses))
 
@@ -284,6 +366,161 @@ func (s *SqlClient) GetTokensForProcessing(limit int, frequencyMinutes int, minM
 	return tokens
 }
 
+const tokenColumns = `contractAddress, createdAt, symbol, marketCap, pairCreatedAt, detector, poolAddress, lpMint, quoteMint, creator,
+	riskScore, riskChecks, riskCheckedAt`
+
+func scanToken(row interface{ Scan(...any) error }) (TokenEntity, error) {
+	var t TokenEntity
+
+	err := row.Scan(&t.ContractAddress, &t.CreatedAt, &t.Symbol, &t.MarketCap, &t.PairCreatedAt, &t.Detector, &t.PoolAddress,
+		&t.LpMint, &t.QuoteMint, &t.Creator, &t.RiskScore, &t.RiskChecks, &t.RiskCheckedAt)
+
+	return t, err
+}
+
+// GetToken returns a token by its contract address, nil if it is not tracked
+func (s *SqlClient) GetToken(address string) *TokenEntity {
+	t, err := scanToken(s.db.QueryRow(`select `+tokenColumns+` from tokens where contractAddress = ?`, address))
+
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("GetToken:", err)
+		}
+
+		return nil
+	}
+
+	return &t
+}
+
+// GetUnscoredTokens returns the oldest tokens without a risk score
+func (s *SqlClient) GetUnscoredTokens(limit int) []TokenEntity {
+	var tokens []TokenEntity
+
+	// tokens that failed to score go last, so new tokens are not held up by them
+	rows, err := s.db.Query(`select `+tokenColumns+` from tokens where riskCheckedAt is null order by scoreAttempts, id limit ?`, limit)
+
+	if err != nil {
+		log.Println("GetUnscoredTokens:", err)
+
+		return tokens
+	}
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+
+		if err != nil {
+			log.Println("GetUnscoredTokens:", err)
+			break
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens
+}
+
+// RecordScoreFailure counts a failed scoring attempt, once the token failed maxAttempts times
+// it is scored 0 with the error so it is not picked up again
+func (s *SqlClient) RecordScoreFailure(address string, reason string, maxAttempts int) {
+	_, err := s.db.Exec(`update tokens set scoreAttempts = scoreAttempts + 1, scoreError = ? where contractAddress = ?`, reason, address)
+
+	if err != nil {
+		log.Println("RecordScoreFailure:", err)
+
+		return
+	}
+
+	query := `update tokens set riskScore = 0, riskCheckedAt = ? where contractAddress = ? and scoreAttempts >= ? and riskCheckedAt is null`
+
+	result, err := s.db.Exec(query, time.Now().UnixMilli(), address, maxAttempts)
+
+	if err != nil {
+		log.Println("RecordScoreFailure:", err)
+
+		return
+	}
+
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Printf("RecordScoreFailure: Token %s scored 0 after %d failed attempts \n", address, maxAttempts)
+	}
+}
+
+func (s *SqlClient) UpdateTokenRisk(address string, score float64, checks string) {
+	query := `update tokens set riskScore = ?, riskChecks = ?, riskCheckedAt = ? where contractAddress = ?`
+
+	_, err := s.db.Exec(query, score, checks, time.Now().UnixMilli(), address)
+
+	if err != nil {
+		log.Println("UpdateTokenRisk:", err)
+	}
+}
+
+// GetRecentTokens returns the tokens detected after since
+func (s *SqlClient) GetRecentTokens(since time.Time) []TokenEntity {
+	var tokens []TokenEntity
+
+	rows, err := s.db.Query(`select `+tokenColumns+` from tokens where createdAt >= ? order by id`, since.UTC().Format(time.DateTime))
+
+	if err != nil {
+		log.Println("GetRecentTokens:", err)
+
+		return tokens
+	}
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+
+		if err != nil {
+			log.Println("GetRecentTokens:", err)
+			break
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens
+}
+
+// GetAllTokens returns every tracked token, oldest first
+func (s *SqlClient) GetAllTokens() []TokenEntity {
+	var tokens []TokenEntity
+
+	rows, err := s.db.Query(`select ` + tokenColumns + ` from tokens order by id`)
+
+	if err != nil {
+		log.Println("GetAllTokens:", err)
+
+		return tokens
+	}
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+
+		if err != nil {
+			log.Println("GetAllTokens:", err)
+			break
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens
+}
+
+// CountTokensByCreator returns how many other tokens were launched by the creator
+func (s *SqlClient) CountTokensByCreator(creator string, exclude string) int {
+	var count int
+
+	err := s.db.QueryRow(`select count(*) from tokens where creator = ? and contractAddress != ?`, creator, exclude).Scan(&count)
+
+	if err != nil {
+		log.Println("CountTokensByCreator:", err)
+	}
+
+	return count
+}
+
 func (s *SqlClient) updateTokenMetadata(token dexscreener.TokensByAddress) {
 	query := `
 	update tokens set
@@ -300,10 +537,11 @@ func (s *SqlClient) updateTokenMetadata(token dexscreener.TokensByAddress) {
 }
 
 func (s *SqlClient) insertMarketData(token dexscreener.TokensByAddress) {
-	query := `insert into market_data("timestamp","marketCap", "fdv", "liquidityUsd", "priceNative", "priceUsd", "contractAddress")
-	 values(?, ?, ?, ?, ?, ?, ?)`
+	query := `insert into market_data("timestamp","marketCap", "fdv", "liquidityUsd", "priceNative", "priceUsd", "contractAddress", "buysM5", "sellsM5", "volumeM5")
+	 values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := s.db.Exec(query, time.Now().UnixMilli(), token.MarketCap, token.Fdv, token.Liquidity.Usd, token.PriceNative, token.PriceUsd, token.BaseToken.Address)
+	_, err := s.db.Exec(query, time.Now().UnixMilli(), token.MarketCap, token.Fdv, token.Liquidity.Usd, token.PriceNative, token.PriceUsd, token.BaseToken.Address,
+		token.Txns.M5.Buys, token.Txns.M5.Sells, token.Volume.M5)
 
 	if err != nil {
 		log.Printf("insertMarketData: Failed to insert marketData %s \n: err: %s", token.BaseToken.Address, err)
@@ -368,80 +606,596 @@ func (s *SqlClient) UpdateTokenData(tokens []dexscreener.TokensByAddress) {
 	}
 }
 
-func (s *SqlClient) UpdateSwapOrder(txHash string, id uint64) {
+// SubmitSwapOrder records the signature of a sent swap, the order waits in submitted
+// until the confirmation tracker decides its outcome
+func (s *SqlClient) SubmitSwapOrder(id uint64, txHash string, lastValidBlockHeight uint64, quotedOutAmount uint64) error {
+	return s.TransitionSwapOrder(id, Transition{
+		To:     OrderStatusSubmitted,
+		Reason: txHash,
+		Set: map[string]any{
+			"txHash":               txHash,
+			"txStatus":             TxStatusSubmitted,
+			"lastValidBlockHeight": lastValidBlockHeight,
+			"quotedOutAmount":      quotedOutAmount,
+			"submittedAt":          time.Now().UnixMilli(),
+		},
+	})
+}
+
+// UpdateSwapOrderConfirmation records the outcome of a submitted swap. Confirmed orders are executed,
+// expired ones drop their signature so they can be retried.
+func (s *SqlClient) UpdateSwapOrderConfirmation(id uint64, c SwapConfirmation) error {
+	t := Transition{Set: map[string]any{"txStatus": c.Status}}
+
+	if c.Err != nil {
+		t.Reason = *c.Err
+	}
+
+	switch c.Status {
+	case TxStatusConfirmed, TxStatusFinalized:
+		t.To = OrderStatusConfirmed
+		t.Set["txSlot"] = c.Slot
+		t.Set["txError"] = nil
+		t.Set["txFee"] = c.Fee
+		t.Set["executedAt"] = time.Now().UnixMilli()
+	case TxStatusExpired:
+		t.To = OrderStatusExpired
+		t.Set["txHash"] = nil
+		t.Set["txSlot"] = nil
+		t.Set["txError"] = c.Err
+		t.Set["txFee"] = nil
+	default:
+		t.To = OrderStatusFailed
+		t.Set["txSlot"] = c.Slot
+		t.Set["txError"] = c.Err
+		t.Set["txFee"] = c.Fee
+		t.Set["lastError"] = c.Err
+	}
+
+	return s.TransitionSwapOrder(id, t)
+}
+
+func (s *SqlClient) UpdateSwapOrderFill(id uint64, f SwapFill) {
+
+	query := `update swap_orders set inAmount = ?, outAmount = ?, networkFee = ?, priorityFee = ?, txFee = ?, slippageBps = ? where id = ?`
+
+	_, err := s.db.Exec(query, f.InAmount, f.OutAmount, f.NetworkFee, f.PriorityFee, f.NetworkFee+f.PriorityFee, f.SlippageBps, id)
+
+	if err != nil {
+		log.Println("UpdateSwapOrderFill:", err)
+
+		return
+	}
+
+}
+
+// GetUnfilledOrders returns the live orders confirmed since the given time whose executed amounts
+// are still missing, because the landed transaction could not be fetched when they were confirmed
+func (s *SqlClient) GetUnfilledOrders(since time.Time) []SwapTradeEntity {
+	query := `select id, fromToken, toToken, txHash, quotedOutAmount from swap_orders
+	where status = ? and simulated = 0 and inAmount is null and txHash is not null and executedAt >= ?
+	and (leaseExpiresAt is null or leaseExpiresAt < ?)`
+
+	rows, err := s.db.Query(query, OrderStatusConfirmed, since.UnixMilli(), time.Now().UnixMilli())
 
-	query := `update swap_orders set txHash = ?, executedAt = ? , lastProcessedAt = ? where id = ?`
+	if err != nil {
+		log.Println("GetUnfilledOrders:", err)
+
+		return nil
+	}
 
-	var params []any
+	var orders []SwapTradeEntity
+
+	for rows.Next() {
+		var o SwapTradeEntity
+
+		err = rows.Scan(&o.Id, &o.FromToken, &o.ToToken, &o.TxHash, &o.QuotedOutAmount)
+
+		if err != nil {
+			log.Println("GetUnfilledOrders:", err)
+			break
+		}
+
+		orders = append(orders, o)
+	}
+
+	return orders
+}
+
+// RecordSimulatedSwap executes a paper trade: the order gets the simulated fill,
+// the paper ledger is debited with the input and the fee and credited with the output
+func (s *SqlClient) RecordSimulatedSwap(id uint64, txHash string, inputMint, outputMint, nativeMint string, f SwapFill) error {
+
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("RecordSimulatedSwap: failed to begin tx: %w", err)
+	}
+
+	defer tx.Rollback()
 
 	now := time.Now().UnixMilli()
+	fee := f.NetworkFee + f.PriorityFee
+
+	err = transitionSwapOrder(tx, id, Transition{
+		To:     OrderStatusSubmitted,
+		Reason: txHash,
+		Set: map[string]any{
+			"txHash":          txHash,
+			"txStatus":        TxStatusSubmitted,
+			"submittedAt":     now,
+			"quotedOutAmount": f.QuotedOutAmount,
+		},
+	})
 
-	if len(txHash) == 0 {
-		params = append(params, nil, nil)
-	} else {
-		params = append(params, txHash, now)
+	if err != nil {
+		return err
 	}
 
-	params = append(params, now, id)
+	err = transitionSwapOrder(tx, id, Transition{
+		To:     OrderStatusConfirmed,
+		Reason: "simulated",
+		Set: map[string]any{
+			"txStatus":    TxStatusConfirmed,
+			"txFee":       fee,
+			"executedAt":  now,
+			"inAmount":    f.InAmount,
+			"outAmount":   f.OutAmount,
+			"networkFee":  f.NetworkFee,
+			"priorityFee": f.PriorityFee,
+			"slippageBps": f.SlippageBps,
+		},
+	})
 
-	_, err := s.db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+
+	entries := []struct {
+		mint  string
+		delta int64
+	}{
+		{inputMint, -int64(f.InAmount)},
+		{outputMint, int64(f.OutAmount)},
+		{nativeMint, -int64(fee)},
+	}
+
+	for _, e := range entries {
+		_, err = tx.Exec(`insert into paper_ledger(orderId, mint, delta) values(?, ?, ?)`, id, e.mint, e.delta)
+
+		if err != nil {
+			return fmt.Errorf("RecordSimulatedSwap: failed to update ledger: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetPaperBalance returns the simulated balance of a mint in atomic units
+func (s *SqlClient) GetPaperBalance(mint string) (int64, error) {
+	var balance int64
+
+	err := s.db.QueryRow(`select coalesce(sum(delta), 0) from paper_ledger where mint = ?`, mint).Scan(&balance)
+
+	return balance, err
+}
+
+// SeedPaperLedger deposits the starting balance, once, into an empty paper ledger
+func (s *SqlClient) SeedPaperLedger(mint string, amount int64) {
+	query := `insert into paper_ledger(mint, delta) select ?, ? where not exists (select 1 from paper_ledger)`
+
+	_, err := s.db.Exec(query, mint, amount)
 
 	if err != nil {
-		log.Println("UpdateSwapOrder:", err)
+		log.Println("SeedPaperLedger:", err)
+	}
+}
 
-		return
+// GetExecutedSwaps returns the filled orders of live or paper trading in the order they were executed
+func (s *SqlClient) GetExecutedSwaps(simulated bool) []SwapTradeEntity {
+	var swaps []SwapTradeEntity
+
+	query := `select id, executedAt, fromToken, toToken, txFee, inAmount, outAmount, simulated from swap_orders
+	where executedAt is not null and inAmount is not null and outAmount is not null and simulated = ? order by executedAt, id`
+
+	rows, err := s.db.Query(query, simulated)
+
+	if err != nil {
+		log.Println("GetExecutedSwaps:", err)
+
+		return swaps
 	}
 
+	for rows.Next() {
+		var st SwapTradeEntity
+		err = rows.Scan(&st.Id, &st.ExecutedAt, &st.FromToken, &st.ToToken, &st.TxFee, &st.InAmount, &st.OutAmount, &st.Simulated)
+
+		if err != nil {
+			log.Println("GetExecutedSwaps:", err)
+			break
+		}
+
+		swaps = append(swaps, st)
+	}
+
+	return swaps
 }
 
-func (s *SqlClient) InsertSwapOrder(st SwapTradeEntity) {
+func (s *SqlClient) InsertPortfolioSnapshot(p PortfolioSnapshotEntity) {
+	query := `insert into portfolio_snapshots(timestamp, simulated, solBalance, positionsValueSol, equitySol, equityUsd, costBasisSol,
+	realizedPnlSol, unrealizedPnlSol, openPositions) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	query := `insert into swap_orders("fromToken", "toToken", "amountDetails", "rules") VALUES (?, ?, ?, ?)`
+	_, err := s.db.Exec(query, p.Timestamp.UnixMilli(), p.Simulated, p.SolBalance, p.PositionsValueSol, p.EquitySol, p.EquityUsd,
+		p.CostBasisSol, p.RealizedPnlSol, p.UnrealizedPnlSol, p.OpenPositions)
 
-	var params []any
+	if err != nil {
+		log.Println("InsertPortfolioSnapshot:", err)
+	}
+}
 
-	params = append(params, st.FromToken)
-	params = append(params, st.ToToken)
+// GetInFlightBuys returns the buy orders that passed the risk checks and are being sent or confirmed,
+// they are not part of the positions yet but already commit their SOL
+func (s *SqlClient) GetInFlightBuys(simulated bool, excludeId uint64) []SwapTradeEntity {
+	var orders []SwapTradeEntity
 
-	if st.AmountDetails != nil {
-		params = append(params, utils.ToString(st.AmountDetails))
-	} else {
-		params = append(params, nil)
+	query := `select id, toToken, amountDetails, status from swap_orders
+	where json_extract(amountDetails, '$.quantitySol') > 0 and status in (?, ?) and simulated = ? and id != ?`
+
+	rows, err := s.db.Query(query, OrderStatusQuoting, OrderStatusSubmitted, simulated, excludeId)
+
+	if err != nil {
+		log.Println("GetInFlightBuys:", err)
+
+		return orders
 	}
 
-	if st.Rules != nil {
-		params = append(params, utils.ToString(st.Rules))
-	} else {
-		params = append(params, nil)
+	for rows.Next() {
+		var o SwapTradeEntity
+
+		err = rows.Scan(&o.Id, &o.ToToken, &o.AmountDetails, &o.Status)
 
+		if err != nil {
+			log.Println("GetInFlightBuys:", err)
+			break
+		}
+
+		orders = append(orders, o)
 	}
 
-	_, err := s.db.Exec(query, params...)
+	return orders
+}
+
+// CountSubmittedSwaps returns how many orders of live or paper trading were sent since the given time
+func (s *SqlClient) CountSubmittedSwaps(since time.Time, simulated bool) int {
+	var count int
+
+	err := s.db.QueryRow(`select count(*) from swap_orders where submittedAt >= ? and simulated = ?`, since.UnixMilli(), simulated).Scan(&count)
+
+	if err != nil {
+		log.Println("CountSubmittedSwaps:", err)
+	}
+
+	return count
+}
+
+// GetRiskState returns the kill switch of live or paper trading
+func (s *SqlClient) GetRiskState(simulated bool) RiskStateEntity {
+	var r RiskStateEntity
+
+	err := s.db.QueryRow(`select killSwitch, reason, trippedAt from risk_state where simulated = ?`, simulated).Scan(&r.KillSwitch, &r.Reason, &r.TrippedAt)
+
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("GetRiskState:", err)
+	}
+
+	return r
+}
+
+// TripKillSwitch halts new buys of the mode until ResetKillSwitch is called
+func (s *SqlClient) TripKillSwitch(reason string, simulated bool) {
+	query := `insert into risk_state(simulated, killSwitch, reason, trippedAt) values(?, 1, ?, ?)
+	on conflict(simulated) do update set killSwitch = 1, reason = excluded.reason, trippedAt = excluded.trippedAt`
+
+	_, err := s.db.Exec(query, simulated, reason, time.Now().UnixMilli())
+
+	if err != nil {
+		log.Println("TripKillSwitch:", err)
+	}
+}
+
+func (s *SqlClient) ResetKillSwitch(simulated bool) {
+	_, err := s.db.Exec(`update risk_state set killSwitch = 0, reason = null, trippedAt = null where simulated = ?`, simulated)
+
+	if err != nil {
+		log.Println("ResetKillSwitch:", err)
+	}
+}
+
+// CancelSwapOrder refuses an order before anything is sent, it is not picked up again
+func (s *SqlClient) CancelSwapOrder(id uint64, reason string) error {
+	return s.TransitionSwapOrder(id, Transition{
+		To:     OrderStatusCancelled,
+		Reason: reason,
+		Set:    map[string]any{"rejectReason": reason},
+	})
+}
+
+func (s *SqlClient) InsertSwapOrder(st SwapTradeEntity) (uint64, error) {
+
+	query := `insert into swap_orders("fromToken", "toToken", "amountDetails", "rules", "parentId", "triggerReason", "exitStep", "strategy", "feeStrategy", "simulated") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// amountDetails, rules and feeStrategy already hold their JSON encoding
+	result, err := s.db.Exec(query, st.FromToken, st.ToToken, st.AmountDetails, st.Rules, st.ParentId, st.TriggerReason, st.ExitStep, st.Strategy, st.FeeStrategy, st.Simulated)
 
 	if err != nil {
 		log.Println("InsertSwapOrder:", err)
 
-		return
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		log.Println("InsertSwapOrder:", err)
+
+		return 0, err
 	}
 
 	log.Print("InsertSwapOrder DONE!")
 
+	return uint64(id), nil
+
 }
 
-func (s *SqlClient) GetPendingTrades() []SwapTradeEntity {
-	query := `select id, fromToken, toToken, amountDetails, rules from swap_orders sp where sp."executedAt" is null`
+// GetOpenPositions returns executed buy orders with exit rules whose position has not been exited in full.
+// Exit orders that failed on-chain or were rejected do not count, the position is evaluated again.
+func (s *SqlClient) GetOpenPositions(simulated bool) []SwapTradeEntity {
+	query := `select b.id, b.fromToken, b.toToken, b.amountDetails, b.rules, b.inAmount, b.outAmount, b.peakPrice, b.feeStrategy from swap_orders b
+	where json_extract(b.amountDetails, '$.quantitySol') > 0 and b.rules is not null and b.executedAt is not null and b.outAmount > 0 and b.simulated = ?
+	and not exists (select 1 from swap_orders s where s.parentId = b.id and s.exitStep is null and s.status not in ` + inactiveStatuses + `)`
+
+	var positions []SwapTradeEntity
+
+	rows, err := s.db.Query(query, simulated)
+
+	if err != nil {
+		log.Println("GetOpenPositions:", err)
+
+		return positions
+	}
+
+	for rows.Next() {
+		var p SwapTradeEntity
+		err = rows.Scan(&p.Id, &p.FromToken, &p.ToToken, &p.AmountDetails, &p.Rules, &p.InAmount, &p.OutAmount, &p.PeakPrice, &p.FeeStrategy)
+
+		if err != nil {
+			log.Println("GetOpenPositions:", err)
+			break
+		}
+
+		positions = append(positions, p)
+	}
+
+	return positions
+}
+
+// GetExitOrders returns the sell orders created for a buy order, leaving out those that failed on-chain or were rejected
+func (s *SqlClient) GetExitOrders(parentId uint64) []SwapTradeEntity {
+	query := `select id, fromToken, toToken, amountDetails, status, exitStep from swap_orders s
+	where s.parentId = ? and s.status not in ` + inactiveStatuses
+
+	var orders []SwapTradeEntity
+
+	rows, err := s.db.Query(query, parentId)
+
+	if err != nil {
+		log.Println("GetExitOrders:", err)
+
+		return orders
+	}
+
+	for rows.Next() {
+		var o SwapTradeEntity
+		err = rows.Scan(&o.Id, &o.FromToken, &o.ToToken, &o.AmountDetails, &o.Status, &o.ExitStep)
+
+		if err != nil {
+			log.Println("GetExitOrders:", err)
+			break
+		}
+
+		orders = append(orders, o)
+	}
+
+	return orders
+}
+
+// GetSoldAmount returns the tokens of a buy order sold by its confirmed exit orders, in atomic units
+func (s *SqlClient) GetSoldAmount(parentId uint64) (uint64, error) {
+	var sold uint64
+
+	err := s.db.QueryRow(`select coalesce(sum(inAmount), 0) from swap_orders where parentId = ? and status = ?`,
+		parentId, OrderStatusConfirmed).Scan(&sold)
+
+	if err != nil {
+		return 0, fmt.Errorf("GetSoldAmount: order %d: %w", parentId, err)
+	}
+
+	return sold, nil
+}
+
+func (s *SqlClient) UpdatePeakPrice(id uint64, price float64) {
+	_, err := s.db.Exec(`update swap_orders set peakPrice = ? where id = ?`, price, id)
+
+	if err != nil {
+		log.Println("UpdatePeakPrice:", err)
+	}
+}
+
+// GetLatestMarketData returns the most recent market data snapshot of a token, nil if there is none
+func (s *SqlClient) GetLatestMarketData(address string) *MarketDataEntity {
+	var m MarketDataEntity
+
+	query := `select timestamp, marketCap, liquidityUsd, priceNative, priceUsd, contractAddress, buysM5, sellsM5, volumeM5 from market_data md
+	where md.contractAddress = ? order by md.timestamp desc limit 1`
+
+	err := s.db.QueryRow(query, address).Scan(&m.Timestamp, &m.MarketCap, &m.LiquidityUsd, &m.PriceNative, &m.PriceUsd, &m.ContractAddress,
+		&m.BuysM5, &m.SellsM5, &m.VolumeM5)
+
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("GetLatestMarketData:", err)
+		}
+
+		return nil
+	}
+
+	return &m
+}
+
+// HasStrategyOrder reports whether the strategy already created a buy order for the token, in paper or live mode
+func (s *SqlClient) HasStrategyOrder(strategy string, token string, simulated bool) bool {
+	var count int
+
+	err := s.db.QueryRow(`select count(*) from swap_orders where strategy = ? and toToken = ? and simulated = ?`, strategy, token, simulated).Scan(&count)
+
+	if err != nil {
+		log.Println("HasStrategyOrder:", err)
+
+		// assume there is one, a duplicate buy is worse than a missed one
+		return true
+	}
+
+	return count > 0
+}
+
+// GetCommittedSol returns the SOL of buy orders that are pending or still hold a position, for a single
+// token or for all tokens when token is empty, in paper or live mode
+func (s *SqlClient) GetCommittedSol(token string, simulated bool) float64 {
+	var committed float64
+
+	query := `select coalesce(sum(json_extract(b.amountDetails, '$.quantitySol')), 0) from swap_orders b
+	where json_extract(b.amountDetails, '$.quantitySol') > 0 and (? = '' or b.toToken = ?) and b.simulated = ?
+	and b.status not in ` + inactiveStatuses + `
+	and not exists (select 1 from swap_orders s where s.parentId = b.id and s.exitStep is null and s.executedAt is not null)`
+
+	err := s.db.QueryRow(query, token, token, simulated).Scan(&committed)
+
+	if err != nil {
+		log.Println("GetCommittedSol:", err)
+	}
+
+	return committed
+}
+
+// GetMint returns the cached mint account, nil if it was never fetched
+func (s *SqlClient) GetMint(address string) *MintEntity {
+	var m MintEntity
+	var supply string
+
+	query := `select address, programId, decimals, supply, mintAuthority, freezeAuthority, extensions, transferFeeBps,
+	transferHookProgram, name, symbol, updatedAt from mints where address = ?`
+
+	err := s.db.QueryRow(query, address).Scan(&m.Address, &m.ProgramId, &m.Decimals, &supply, &m.MintAuthority, &m.FreezeAuthority,
+		&m.Extensions, &m.TransferFeeBps, &m.TransferHookProgram, &m.Name, &m.Symbol, &m.UpdatedAt)
+
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("GetMint:", err)
+		}
+
+		return nil
+	}
+
+	m.Supply, _ = strconv.ParseUint(supply, 10, 64)
+
+	return &m
+}
+
+func (s *SqlClient) UpsertMint(m MintEntity) {
+	query := `insert into mints(address, programId, decimals, supply, mintAuthority, freezeAuthority, extensions, transferFeeBps,
+	transferHookProgram, name, symbol, updatedAt) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, current_timestamp)
+	on conflict(address) do update set programId = excluded.programId, decimals = excluded.decimals, supply = excluded.supply,
+	mintAuthority = excluded.mintAuthority, freezeAuthority = excluded.freezeAuthority, extensions = excluded.extensions,
+	transferFeeBps = excluded.transferFeeBps, transferHookProgram = excluded.transferHookProgram, name = excluded.name,
+	symbol = excluded.symbol, updatedAt = excluded.updatedAt`
+
+	_, err := s.db.Exec(query, m.Address, m.ProgramId, m.Decimals, strconv.FormatUint(m.Supply, 10), m.MintAuthority, m.FreezeAuthority,
+		m.Extensions, m.TransferFeeBps, m.TransferHookProgram, m.Name, m.Symbol)
+
+	if err != nil {
+		log.Println("UpsertMint:", err)
+	}
+}
+
+// GetMarketDataSeries returns the market data snapshots of all tokens in chronological order
+func (s *SqlClient) GetMarketDataSeries() []MarketDataEntity {
+	var marketData []MarketDataEntity
+
+	query := `select timestamp, marketCap, fdv, liquidityUsd, priceNative, priceUsd, contractAddress, buysM5, sellsM5, volumeM5
+	from market_data md order by md.timestamp, md.id`
 
 	rows, err := s.db.Query(query)
 
+	if err != nil {
+		log.Println("GetMarketDataSeries:", err)
+
+		return marketData
+	}
+
+	for rows.Next() {
+		var m MarketDataEntity
+
+		err = rows.Scan(&m.Timestamp, &m.MarketCap, &m.Fdv, &m.LiquidityUsd, &m.PriceNative, &m.PriceUsd, &m.ContractAddress,
+			&m.BuysM5, &m.SellsM5, &m.VolumeM5)
+
+		if err != nil {
+			log.Println("GetMarketDataSeries:", err)
+			break
+		}
+
+		marketData = append(marketData, m)
+	}
+
+	return marketData
+}
+
+// GetSolUsdPrice derives the SOL price in USD from the latest market data snapshot of a SOL-quoted pair,
+// priceNative of other pairs is in their quote token. 0 if there is none.
+func (s *SqlClient) GetSolUsdPrice(nativeMint string) float64 {
+	var price float64
+
+	query := `select md.priceUsd / md.priceNative from market_data md join tokens t on t.contractAddress = md.contractAddress
+	where t.quoteMint = ? and md.priceNative > 0 and md.priceUsd > 0 order by md.timestamp desc limit 1`
+
+	err := s.db.QueryRow(query, nativeMint).Scan(&price)
+
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("GetSolUsdPrice:", err)
+	}
+
+	return price
+}
+
+// GetPendingTrades returns the pending orders created in the given mode, orders queued in paper mode
+// are never sent for real and live orders are never simulated
+func (s *SqlClient) GetPendingTrades(simulated bool) []SwapTradeEntity {
+	// orders that failed an attempt wait for their retry time
+	query := `select id, fromToken, toToken, amountDetails, rules, status, attempts, feeStrategy from swap_orders sp
+	where sp.status = ? and sp.simulated = ? and (sp.nextRetryAt is null or sp.nextRetryAt <= ?)`
+
+	rows, err := s.db.Query(query, OrderStatusPending, simulated, time.Now().UnixMilli())
+
 	if err != nil {
 		log.Print("GetPendingTrades: dbQuery Error", err)
+
+		return nil
 	}
 
 	var trades []SwapTradeEntity
 
 	for rows.Next() {
 		var trade SwapTradeEntity
-		rows.Scan(&trade.Id, &trade.FromToken, &trade.ToToken, &trade.AmountDetails, &trade.Rules)
+		rows.Scan(&trade.Id, &trade.FromToken, &trade.ToToken, &trade.AmountDetails, &trade.Rules, &trade.Status, &trade.Attempts, &trade.FeeStrategy)
 
 		trades = append(trades, trade)
 	}
@@ -459,6 +1213,20 @@ func New(dbPath string) *SqlClient {
 		log.Fatal("unable to use data source name", err)
 	}
 
-	return &SqlClient{db: db}
+	s := &SqlClient{db: db}
+	s.migrate()
+
+	return s
 
 }
+
+// RecordSwapSimulation stores the preflight simulation of the transaction signed for an order
+func (s *SqlClient) RecordSwapSimulation(id uint64, sim SwapSimulation) {
+	query := `update swap_orders set simulationLogs = ?, simulationUnits = ?, simulationError = ?, simulatedOutAmount = ? where id = ?`
+
+	_, err := s.db.Exec(query, utils.ToString(sim.Logs), sim.UnitsConsumed, sim.Err, sim.OutAmount, id)
+
+	if err != nil {
+		log.Println("RecordSwapSimulation:", err)
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestClient opens a migrated database in a temporary directory
+func newTestClient(t *testing.T) *SqlClient {
+	t.Helper()
+
+	s := New(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func insertOrder(t *testing.T, s *SqlClient, o SwapTradeEntity) uint64 {
+	t.Helper()
+
+	id, err := s.InsertSwapOrder(o)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func TestGetPendingTradesByMode(t *testing.T) {
+	s := newTestClient(t)
+
+	paper := insertOrder(t, s, SwapTradeEntity{FromToken: "sol", ToToken: "mint", Simulated: true})
+	live := insertOrder(t, s, SwapTradeEntity{FromToken: "sol", ToToken: "mint"})
+
+	ids := func(trades []SwapTradeEntity) []uint64 {
+		var ids []uint64
+
+		for _, tr := range trades {
+			ids = append(ids, tr.Id)
+		}
+
+		return ids
+	}
+
+	if got := ids(s.GetPendingTrades(false)); len(got) != 1 || got[0] != live {
+		t.Errorf("live pending trades = %v, want only %d", got, live)
+	}
+
+	if got := ids(s.GetPendingTrades(true)); len(got) != 1 || got[0] != paper {
+		t.Errorf("paper pending trades = %v, want only %d", got, paper)
+	}
+}
+
+func TestKillSwitchByMode(t *testing.T) {
+	s := newTestClient(t)
+
+	s.TripKillSwitch("paper loss", true)
+
+	if !s.GetRiskState(true).KillSwitch {
+		t.Error("paper kill switch is not tripped")
+	}
+
+	if s.GetRiskState(false).KillSwitch {
+		t.Error("a paper loss tripped the live kill switch")
+	}
+
+	s.TripKillSwitch("live loss", false)
+	s.ResetKillSwitch(true)
+
+	if s.GetRiskState(true).KillSwitch {
+		t.Error("paper kill switch is still tripped after the reset")
+	}
+
+	if state := s.GetRiskState(false); !state.KillSwitch || *state.Reason != "live loss" {
+		t.Errorf("live risk state = %+v, want tripped by the live loss", state)
+	}
+}
@@ -1,12 +1,14 @@
 package db
 
 import (
+	"solana-bot/utils"
 	"time"
 )
 
 type RpcLogEntity struct {
 	Id          uint64
 	Signature   string
+	Detector    *string // nullable field, empty for logs recorded before detectors existed
 	CreatedAt   time.Time
 	ProcessedAt *time.Time // nullable field
 }
@@ -19,6 +21,59 @@ type TokenEntity struct {
 	Symbol          *string    // nullable field
 	MarketCap       *float64   // nullable filed
 	PairCreatedAt   *time.Time // nullable filed
+	Detector        *string    // nullable field
+	PoolAddress     *string    // nullable field
+	LpMint          *string    // nullable field
+	QuoteMint       *string    // nullable field
+	Creator         *string    // nullable field
+	RiskScore       *float64   // nullable field, 0-100, higher is safer
+	RiskChecks      *string    // nullable field, stored as JSON string but will be deserialized to []RiskCheck
+	RiskCheckedAt   *time.Time // nullable field
+}
+
+// RiskCheck is the outcome of one rug-risk check, skipped checks do not count towards the score
+type RiskCheck struct {
+	Name    string  `json:"name"`
+	Passed  bool    `json:"passed"`
+	Skipped bool    `json:"skipped"`
+	Weight  float64 `json:"weight"`
+	Detail  string  `json:"detail"`
+}
+
+type PortfolioSnapshotEntity struct {
+	Id                uint64
+	Timestamp         time.Time
+	Simulated         bool
+	SolBalance        float64
+	PositionsValueSol float64
+	EquitySol         float64
+	EquityUsd         *float64 // nullable field, unknown without a SOL/USD price
+	CostBasisSol      float64
+	RealizedPnlSol    float64
+	UnrealizedPnlSol  float64
+	OpenPositions     int
+}
+
+type RiskStateEntity struct {
+	KillSwitch bool
+	Reason     *string    // nullable field
+	TrippedAt  *time.Time // nullable field
+}
+
+// MintEntity is the decoded SPL mint account of a token
+type MintEntity struct {
+	Address             string
+	ProgramId           string // spl token or token-2022
+	Decimals            int
+	Supply              uint64
+	MintAuthority       *string // nullable field, null once revoked
+	FreezeAuthority     *string // nullable field, null once revoked
+	Extensions          *string // nullable field, JSON array of token-2022 extension names
+	TransferFeeBps      *int    // nullable field, current token-2022 transfer fee
+	TransferHookProgram *string // nullable field, token-2022 transfer hook program
+	Name                *string // nullable field, token-2022 metadata extension
+	Symbol              *string // nullable field, token-2022 metadata extension
+	UpdatedAt           time.Time
 }
 
 type MarketDataEntity struct {
@@ -30,15 +85,38 @@ type MarketDataEntity struct {
 	PriceNative     float64
 	PriceUsd        float64
 	ContractAddress string
+	BuysM5          *int     // nullable field, empty for snapshots taken before it was recorded
+	SellsM5         *int     // nullable field
+	VolumeM5        *float64 // nullable field
+}
+
+const (
+	RulesTypePercent = "percent" // thresholds are percentages of the entry price
+	RulesTypePrice   = "price"   // thresholds are absolute prices in SOL per token
+)
+
+// ExitStep sells a fraction of the bought amount once the price reaches a multiple of the entry price
+type ExitStep struct {
+	Multiple float32 `json:"multiple"`
+	Fraction float32 `json:"fraction"`
 }
 
 type SwapRules struct {
+	Type       string  `json:"type"` // percent (default) or price
 	TakeProfit float32 `json:"takeProfit"`
 	StopLoss   float32 `json:"stopLoss"`
+
+	// laddered exits, once every step is done the rest of the position follows the trailing stop
+	Ladder       []ExitStep `json:"ladder"`
+	TrailingStop float32    `json:"trailingStop"` // percent below the highest price since entry
 }
 
 type AmountDetails struct {
-	QuantitySol float32 `json:"quantitySol"`
+	QuantitySol float32 `json:"quantitySol"` // buy orders
+
+	// sell orders, without either of them the whole balance is sold
+	Fraction      float32 `json:"fraction"`      // fraction of the token balance
+	QuantityToken float64 `json:"quantityToken"` // exact amount in whole tokens
 }
 
 type SwapTradeEntity struct {
@@ -50,8 +128,99 @@ type SwapTradeEntity struct {
 	FromToken string `json:"fromToken"`
 	ToToken   string `json:"toToken"`
 
-	TxHash *string // nullable field
-	Rules  *string `json:"rules"` // nullable field, stored as JSON string but will be deserialized to struct SwapRules
+	Status      string     // one of the OrderStatus constants
+	Attempts    int        // executions started so far
+	LastError   *string    // nullable field, why the last attempt failed
+	NextRetryAt *time.Time // nullable field, a pending order is not executed before
+
+	TxHash               *string // nullable field
+	TxStatus             *string // nullable field, one of the TxStatus constants
+	TxSlot               *uint64 // nullable field
+	TxError              *string // nullable field
+	TxFee                *uint64 // nullable field, lamports
+	LastValidBlockHeight *uint64 // nullable field
+
+	QuotedOutAmount *uint64  // nullable field
+	InAmount        *uint64  // nullable field, executed input in atomic units
+	OutAmount       *uint64  // nullable field, executed output in atomic units
+	NetworkFee      *uint64  // nullable field, base signature fee in lamports
+	PriorityFee     *uint64  // nullable field, lamports
+	SlippageBps     *float64 // nullable field, realized slippage against the quote, positive is worse
+
+	Rules *string `json:"rules"` // nullable field, stored as JSON string but will be deserialized to struct SwapRules
 
 	AmountDetails *string `json:"amountDetails"` // nullable field, stored as JSON string but will be deserialized to struct AmountDetails
+
+	ParentId      *uint64    `json:"parentId"`      // nullable field, the buy order a sell order exits
+	TriggerReason *string    `json:"triggerReason"` // nullable field, why an exit rule or entry strategy created this order
+	ExitStep      *int       `json:"exitStep"`      // nullable field, ladder step of a sell order, null exits the rest of the position
+	PeakPrice     *float64   `json:"peakPrice"`     // nullable field, highest price seen since a buy order was filled
+	Strategy      *string    `json:"strategy"`      // nullable field, entry strategy that created a buy order
+	Simulated     bool       `json:"simulated"`     // paper trade, filled at the quote without sending a transaction
+	RejectReason  *string    `json:"rejectReason"`  // nullable field, why the order was refused before sending
+	SubmittedAt   *time.Time // nullable field
+	FeeStrategy   *string    `json:"feeStrategy"` // nullable field, stored as JSON string but will be deserialized to config.PriorityFeeConfig
+
+	ComputeUnitLimit *uint64 // nullable field, of the last signed transaction
+	ComputeUnitPrice *uint64 // nullable field, micro-lamports, of the last signed transaction
+
+	SimulationLogs     *string // nullable field, JSON array of the program logs of the last preflight simulation
+	SimulationUnits    *uint64 // nullable field, compute units consumed in the simulation
+	SimulationError    *string // nullable field, why the simulation failed or was refused
+	SimulatedOutAmount *uint64 // nullable field, output the simulation credited to the wallet
+
+	LeaseOwner     *string    // nullable field, process executing the order
+	LeaseExpiresAt *time.Time // nullable field, the order may be taken over after
+}
+
+// IsBuy reports whether the order swaps sol into a token
+func (st SwapTradeEntity) IsBuy() bool {
+	if st.AmountDetails == nil {
+		return false
+	}
+
+	return utils.Deserialize[AmountDetails](*st.AmountDetails).QuantitySol > 0
+}
+
+const (
+	TxStatusSubmitted = "submitted"
+	TxStatusConfirmed = "confirmed"
+	TxStatusFinalized = "finalized"
+	TxStatusFailed    = "failed"
+	TxStatusExpired   = "expired"
+)
+
+// SignedSwap is a transaction signed for an order, recorded before it is sent
+type SignedSwap struct {
+	TxHash               string
+	LastValidBlockHeight uint64
+	QuotedOutAmount      uint64
+	ComputeUnitLimit     uint64
+	ComputeUnitPrice     uint64 // micro-lamports
+}
+
+// SwapSimulation is the outcome of the preflight simulation of a signed swap
+type SwapSimulation struct {
+	Logs          []string
+	UnitsConsumed uint64
+	OutAmount     uint64
+	Err           *string
+}
+
+// SwapConfirmation is the final on-chain outcome of a submitted swap transaction
+type SwapConfirmation struct {
+	Status string
+	Slot   uint64
+	Err    *string
+	Fee    uint64
+}
+
+// SwapFill is what a confirmed swap actually exchanged
+type SwapFill struct {
+	QuotedOutAmount uint64
+	InAmount        uint64
+	OutAmount       uint64
+	NetworkFee      uint64
+	PriorityFee     uint64
+	SlippageBps     float64
 }
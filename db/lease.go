@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrLeaseLost means another owner took the order over, the previous owner must stop executing it
+var ErrLeaseLost = errors.New("order lease lost")
+
+// AcquireSwapOrderLease claims an order for the owner until ttl from now. It fails while any owner, the
+// same one included, holds an unexpired lease, the check and the claim are one statement so only one can win.
+func (s *SqlClient) AcquireSwapOrderLease(id uint64, owner string, ttl time.Duration) bool {
+	now := time.Now()
+
+	result, err := s.db.Exec(`update swap_orders set leaseOwner = ?, leaseExpiresAt = ?
+	where id = ? and (leaseOwner is null or leaseExpiresAt is null or leaseExpiresAt < ?)`,
+		owner, now.Add(ttl).UnixMilli(), id, now.UnixMilli())
+
+	if err != nil {
+		log.Println("AcquireSwapOrderLease:", err)
+
+		return false
+	}
+
+	n, _ := result.RowsAffected()
+
+	return n == 1
+}
+
+// RenewSwapOrderLease extends a lease that is still held by the owner
+func (s *SqlClient) RenewSwapOrderLease(id uint64, owner string, ttl time.Duration) error {
+	result, err := s.db.Exec(`update swap_orders set leaseExpiresAt = ? where id = ? and leaseOwner = ?`,
+		time.Now().Add(ttl).UnixMilli(), id, owner)
+
+	if err != nil {
+		return fmt.Errorf("RenewSwapOrderLease: order %d: %w", id, err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("RenewSwapOrderLease: order %d is no longer leased by %s: %w", id, owner, ErrLeaseLost)
+	}
+
+	return nil
+}
+
+// ReleaseSwapOrderLease gives up a lease held by the owner
+func (s *SqlClient) ReleaseSwapOrderLease(id uint64, owner string) {
+	_, err := s.db.Exec(`update swap_orders set leaseOwner = null, leaseExpiresAt = null where id = ? and leaseOwner = ?`, id, owner)
+
+	if err != nil {
+		log.Println("ReleaseSwapOrderLease:", err)
+	}
+}
+
+// RecordSignedSwap stores the signature of a transaction before it is sent, so that an order
+// interrupted while sending can be looked up on-chain instead of being sent twice. It fails with
+// ErrLeaseLost when the owner no longer holds the lease, the transaction must not be sent then.
+func (s *SqlClient) RecordSignedSwap(id uint64, owner string, sw SignedSwap) error {
+	result, err := s.db.Exec(`update swap_orders set txHash = ?, lastValidBlockHeight = ?, quotedOutAmount = ?, computeUnitLimit = ?, computeUnitPrice = ?
+	where id = ? and status = ? and leaseOwner = ? and leaseExpiresAt >= ?`,
+		sw.TxHash, sw.LastValidBlockHeight, sw.QuotedOutAmount, sw.ComputeUnitLimit, sw.ComputeUnitPrice, id, OrderStatusQuoting, owner, time.Now().UnixMilli())
+
+	if err != nil {
+		return fmt.Errorf("RecordSignedSwap: order %d: %w", id, err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("RecordSignedSwap: order %d is no longer %s or leased by %s: %w", id, OrderStatusQuoting, owner, ErrLeaseLost)
+	}
+
+	return nil
+}
+
+// GetInterruptedOrders returns the orders of the given mode left between two statuses by a process that
+// stopped, quoting, submitted or expired without a live lease
+func (s *SqlClient) GetInterruptedOrders(simulated bool) []SwapTradeEntity {
+	query := `select id, fromToken, toToken, amountDetails, status, attempts, txHash, lastValidBlockHeight, quotedOutAmount from swap_orders
+	where status in (?, ?, ?) and simulated = ? and (leaseExpiresAt is null or leaseExpiresAt < ?)`
+
+	rows, err := s.db.Query(query, OrderStatusQuoting, OrderStatusSubmitted, OrderStatusExpired, simulated, time.Now().UnixMilli())
+
+	if err != nil {
+		log.Println("GetInterruptedOrders:", err)
+
+		return nil
+	}
+
+	var orders []SwapTradeEntity
+
+	for rows.Next() {
+		var o SwapTradeEntity
+
+		err = rows.Scan(&o.Id, &o.FromToken, &o.ToToken, &o.AmountDetails, &o.Status, &o.Attempts, &o.TxHash, &o.LastValidBlockHeight, &o.QuotedOutAmount)
+
+		if err != nil {
+			log.Println("GetInterruptedOrders:", err)
+			break
+		}
+
+		orders = append(orders, o)
+	}
+
+	return orders
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSwapOrderLease(t *testing.T) {
+	s := newTestClient(t)
+	id := insertOrder(t, s, SwapTradeEntity{FromToken: "sol", ToToken: "mint"})
+
+	if err := s.TransitionSwapOrder(id, Transition{To: OrderStatusQuoting}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.AcquireSwapOrderLease(id, "a", time.Minute) {
+		t.Fatal("first acquire failed")
+	}
+
+	// the same owner included, nobody takes over a lease that did not expire
+	for _, owner := range []string{"a", "b"} {
+		if s.AcquireSwapOrderLease(id, owner, time.Minute) {
+			t.Errorf("acquire by %s succeeded while a holds the lease", owner)
+		}
+	}
+
+	s.ReleaseSwapOrderLease(id, "b")
+
+	if s.AcquireSwapOrderLease(id, "b", time.Minute) {
+		t.Error("release by b cleared the lease of a")
+	}
+
+	if err := s.RecordSignedSwap(id, "b", SignedSwap{TxHash: "sig-b"}); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RecordSignedSwap by b = %v, want ErrLeaseLost", err)
+	}
+
+	if err := s.RecordSignedSwap(id, "a", SignedSwap{TxHash: "sig-a"}); err != nil {
+		t.Errorf("RecordSignedSwap by a failed: %s", err)
+	}
+
+	s.ReleaseSwapOrderLease(id, "a")
+
+	if !s.AcquireSwapOrderLease(id, "b", time.Minute) {
+		t.Error("acquire after release failed")
+	}
+}
+
+func TestSwapOrderLeaseExpired(t *testing.T) {
+	s := newTestClient(t)
+	id := insertOrder(t, s, SwapTradeEntity{FromToken: "sol", ToToken: "mint"})
+
+	if !s.AcquireSwapOrderLease(id, "a", -time.Second) {
+		t.Fatal("first acquire failed")
+	}
+
+	if !s.AcquireSwapOrderLease(id, "b", time.Minute) {
+		t.Error("acquire of an expired lease failed")
+	}
+
+	if err := s.RenewSwapOrderLease(id, "a", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewSwapOrderLease by a = %v, want ErrLeaseLost", err)
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// lifecycle of a swap order
+const (
+	OrderStatusPending   = "pending"   // waiting to be executed, or retried after nextRetryAt
+	OrderStatusQuoting   = "quoting"   // being quoted, built and signed
+	OrderStatusSubmitted = "submitted" // sent, waiting for the confirmation
+	OrderStatusConfirmed = "confirmed" // landed and succeeded on-chain
+	OrderStatusFailed    = "failed"    // failed on-chain or ran out of attempts
+	OrderStatusExpired   = "expired"   // the blockhash expired before the transaction landed
+	OrderStatusCancelled = "cancelled" // refused before sending, e.g. by the risk checks
+)
+
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusQuoting, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusQuoting:   {OrderStatusSubmitted, OrderStatusPending, OrderStatusFailed, OrderStatusCancelled},
+	OrderStatusSubmitted: {OrderStatusConfirmed, OrderStatusFailed, OrderStatusExpired},
+	OrderStatusExpired:   {OrderStatusPending, OrderStatusFailed},
+}
+
+// inactiveStatuses will not fill anymore, sql list for queries
+const inactiveStatuses = `('failed', 'expired', 'cancelled')`
+
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// Transition moves an order to a new status, Set holds the columns updated together with it
+type Transition struct {
+	To     string
+	Reason string
+	Set    map[string]any
+}
+
+type SwapOrderEventEntity struct {
+	Id         uint64
+	OrderId    uint64
+	CreatedAt  time.Time
+	FromStatus string
+	ToStatus   string
+	Reason     *string // nullable field
+}
+
+// TransitionSwapOrder is the only way an order changes status: it checks the transition is allowed,
+// updates the order and records the change in swap_order_events, all in one transaction
+func (s *SqlClient) TransitionSwapOrder(id uint64, t Transition) error {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("TransitionSwapOrder: failed to begin tx: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	err = transitionSwapOrder(tx, id, t)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func transitionSwapOrder(tx *sql.Tx, id uint64, t Transition) error {
+	var from string
+
+	err := tx.QueryRow(`select status from swap_orders where id = ?`, id).Scan(&from)
+
+	if err != nil {
+		return fmt.Errorf("TransitionSwapOrder: order %d: %w", id, err)
+	}
+
+	if !slices.Contains(orderTransitions[from], t.To) {
+		return fmt.Errorf("TransitionSwapOrder: order %d from %s to %s: %w", id, from, t.To, ErrInvalidTransition)
+	}
+
+	now := time.Now().UnixMilli()
+
+	query := `update swap_orders set status = ?, lastProcessedAt = ?`
+	params := []any{t.To, now}
+
+	// sorted so the statement is the same for the same columns
+	columns := make([]string, 0, len(t.Set))
+
+	for column := range t.Set {
+		columns = append(columns, column)
+	}
+
+	slices.Sort(columns)
+
+	for _, column := range columns {
+		query += fmt.Sprintf(`, "%s" = ?`, column)
+		params = append(params, t.Set[column])
+	}
+
+	// the status is part of the condition, a concurrent transition makes this one fail
+	result, err := tx.Exec(query+` where id = ? and status = ?`, append(params, id, from)...)
+
+	if err != nil {
+		return fmt.Errorf("TransitionSwapOrder: failed to update order %d: %w", id, err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("TransitionSwapOrder: order %d is no longer %s: %w", id, from, ErrInvalidTransition)
+	}
+
+	var reason *string
+
+	if len(t.Reason) > 0 {
+		reason = &t.Reason
+	}
+
+	_, err = tx.Exec(`insert into swap_order_events(orderId, createdAt, fromStatus, toStatus, reason) values(?, ?, ?, ?, ?)`,
+		id, now, from, t.To, reason)
+
+	if err != nil {
+		return fmt.Errorf("TransitionSwapOrder: failed to record event of order %d: %w", id, err)
+	}
+
+	log.Printf("TransitionSwapOrder: Order %d %s -> %s %s \n", id, from, t.To, t.Reason)
+
+	return nil
+}
+
+// GetSwapOrderEvents returns the status history of an order, oldest first
+func (s *SqlClient) GetSwapOrderEvents(id uint64) []SwapOrderEventEntity {
+	var events []SwapOrderEventEntity
+
+	rows, err := s.db.Query(`select id, orderId, createdAt, fromStatus, toStatus, reason from swap_order_events where orderId = ? order by id`, id)
+
+	if err != nil {
+		log.Println("GetSwapOrderEvents:", err)
+
+		return events
+	}
+
+	for rows.Next() {
+		var e SwapOrderEventEntity
+
+		err = rows.Scan(&e.Id, &e.OrderId, &e.CreatedAt, &e.FromStatus, &e.ToStatus, &e.Reason)
+
+		if err != nil {
+			log.Println("GetSwapOrderEvents:", err)
+			break
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
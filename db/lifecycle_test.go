@@ -0,0 +1,149 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var allStatuses = []string{
+	OrderStatusPending, OrderStatusQuoting, OrderStatusSubmitted, OrderStatusConfirmed,
+	OrderStatusFailed, OrderStatusExpired, OrderStatusCancelled,
+}
+
+// orderInStatus inserts an order and puts it in status without going through the lifecycle
+func orderInStatus(t *testing.T, s *SqlClient, status string) uint64 {
+	t.Helper()
+
+	id := insertOrder(t, s, SwapTradeEntity{FromToken: "sol", ToToken: "mint"})
+
+	if _, err := s.db.Exec(`update swap_orders set status = ? where id = ?`, status, id); err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func orderStatus(t *testing.T, s *SqlClient, id uint64) string {
+	t.Helper()
+
+	var status string
+
+	if err := s.db.QueryRow(`select status from swap_orders where id = ?`, id).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	return status
+}
+
+func TestTransitionSwapOrder(t *testing.T) {
+	s := newTestClient(t)
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			allowed := slices.Contains(orderTransitions[from], to)
+
+			t.Run(fmt.Sprintf("%s to %s", from, to), func(t *testing.T) {
+				id := orderInStatus(t, s, from)
+				err := s.TransitionSwapOrder(id, Transition{To: to, Reason: "test"})
+				events := s.GetSwapOrderEvents(id)
+
+				if !allowed {
+					if !errors.Is(err, ErrInvalidTransition) {
+						t.Errorf("err = %v, want ErrInvalidTransition", err)
+					}
+
+					if got := orderStatus(t, s, id); got != from || len(events) != 0 {
+						t.Errorf("status = %s with %d events, want %s unchanged", got, len(events), from)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("err = %s, want the transition", err)
+				}
+
+				if got := orderStatus(t, s, id); got != to {
+					t.Errorf("status = %s, want %s", got, to)
+				}
+
+				if len(events) != 1 || events[0].FromStatus != from || events[0].ToStatus != to || *events[0].Reason != "test" {
+					t.Errorf("events = %+v, want one %s -> %s", events, from, to)
+				}
+			})
+		}
+	}
+}
+
+func TestTransitionSwapOrderSetsColumns(t *testing.T) {
+	s := newTestClient(t)
+	id := orderInStatus(t, s, OrderStatusPending)
+
+	err := s.TransitionSwapOrder(id, Transition{To: OrderStatusCancelled, Set: map[string]any{"rejectReason": "limit", "attempts": 2}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reason string
+	var attempts int
+
+	if err := s.db.QueryRow(`select rejectReason, attempts from swap_orders where id = ?`, id).Scan(&reason, &attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason != "limit" || attempts != 2 {
+		t.Errorf("rejectReason = %s, attempts = %d, want limit and 2", reason, attempts)
+	}
+}
+
+func TestTransitionSwapOrderUnknownOrder(t *testing.T) {
+	s := newTestClient(t)
+
+	if err := s.TransitionSwapOrder(42, Transition{To: OrderStatusQuoting}); err == nil {
+		t.Error("transition of an order that does not exist succeeded")
+	}
+}
+
+// Orders are only moved from the status they were read in, of several executions claiming the same
+// pending order exactly one moves it to quoting and records the event.
+func TestTransitionSwapOrderCompareAndSet(t *testing.T) {
+	s := newTestClient(t)
+	id := orderInStatus(t, s, OrderStatusPending)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if s.TransitionSwapOrder(id, Transition{To: OrderStatusQuoting}) == nil {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if won != 1 {
+		t.Errorf("%d transitions succeeded, want 1", won)
+	}
+
+	if events := s.GetSwapOrderEvents(id); len(events) != 1 {
+		t.Errorf("recorded %d events, want 1", len(events))
+	}
+
+	// a transition read from the old status is rejected once the order moved on
+	if err := s.TransitionSwapOrder(id, Transition{To: OrderStatusQuoting}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second claim = %v, want ErrInvalidTransition", err)
+	}
+}
@@ -8,9 +8,9 @@ CREATE TABLE tokens (
 
 CREATE UNIQUE INDEX tokens_unique_contractAddress  ON tokens("contractAddress");
 
-ALTER TABLE tokens ADD symbol VARCHAR
-ALTER TABLE tokens ADD marketCap REAL DEFAULT 0
-ALTER TABLE tokens ADD pairCreatedAt DATETIME
+ALTER TABLE tokens ADD symbol VARCHAR;
+ALTER TABLE tokens ADD marketCap REAL DEFAULT 0;
+ALTER TABLE tokens ADD pairCreatedAt DATETIME;
 
 CREATE INDEX tokens_marketCap  ON tokens("marketCap");
 CREATE INDEX tokens_symbol ON tokens("symbol");
@@ -0,0 +1,8 @@
+-- UP
+-- launch detectors
+ALTER TABLE rpc_logs ADD detector VARCHAR(255);
+ALTER TABLE tokens ADD detector VARCHAR(255);
+
+-- DOWN
+ALTER TABLE rpc_logs DROP COLUMN detector;
+ALTER TABLE tokens DROP COLUMN detector;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- UP
+-- pool accounts extracted from the pool creation instruction
+ALTER TABLE tokens ADD poolAddress VARCHAR(255);
+ALTER TABLE tokens ADD lpMint VARCHAR(255);
+ALTER TABLE tokens ADD quoteMint VARCHAR(255);
+ALTER TABLE tokens ADD creator VARCHAR(255);
+
+CREATE INDEX tokens_creator ON tokens("creator");
+
+-- DOWN
+DROP INDEX tokens_creator;
+ALTER TABLE tokens DROP COLUMN poolAddress;
+ALTER TABLE tokens DROP COLUMN lpMint;
+ALTER TABLE tokens DROP COLUMN quoteMint;
+ALTER TABLE tokens DROP COLUMN creator;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- UP
+-- swap confirmation tracking
+ALTER TABLE swap_orders ADD txStatus VARCHAR(255);
+ALTER TABLE swap_orders ADD txSlot INTEGER;
+ALTER TABLE swap_orders ADD txError TEXT;
+ALTER TABLE swap_orders ADD txFee INTEGER;
+ALTER TABLE swap_orders ADD lastValidBlockHeight INTEGER;
+
+-- DOWN
+ALTER TABLE swap_orders DROP COLUMN txStatus;
+ALTER TABLE swap_orders DROP COLUMN txSlot;
+ALTER TABLE swap_orders DROP COLUMN txError;
+ALTER TABLE swap_orders DROP COLUMN txFee;
+ALTER TABLE swap_orders DROP COLUMN lastValidBlockHeight;
\ No newline at end of file
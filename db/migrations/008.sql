@@ -0,0 +1,16 @@
+-- UP
+-- fill accounting, amounts in atomic units of their mint, fees in lamports
+ALTER TABLE swap_orders ADD quotedOutAmount INTEGER;
+ALTER TABLE swap_orders ADD inAmount INTEGER;
+ALTER TABLE swap_orders ADD outAmount INTEGER;
+ALTER TABLE swap_orders ADD networkFee INTEGER;
+ALTER TABLE swap_orders ADD priorityFee INTEGER;
+ALTER TABLE swap_orders ADD slippageBps REAL;
+
+-- DOWN
+ALTER TABLE swap_orders DROP COLUMN quotedOutAmount;
+ALTER TABLE swap_orders DROP COLUMN inAmount;
+ALTER TABLE swap_orders DROP COLUMN outAmount;
+ALTER TABLE swap_orders DROP COLUMN networkFee;
+ALTER TABLE swap_orders DROP COLUMN priorityFee;
+ALTER TABLE swap_orders DROP COLUMN slippageBps;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- UP
+-- exit orders created by the position monitor
+ALTER TABLE swap_orders ADD parentId INTEGER;
+ALTER TABLE swap_orders ADD triggerReason TEXT;
+ALTER TABLE swap_orders ADD exitStep INTEGER;
+ALTER TABLE swap_orders ADD peakPrice REAL;
+
+CREATE INDEX swap_orders_parentId ON swap_orders("parentId");
+
+-- DOWN
+DROP INDEX swap_orders_parentId;
+ALTER TABLE swap_orders DROP COLUMN parentId;
+ALTER TABLE swap_orders DROP COLUMN triggerReason;
+ALTER TABLE swap_orders DROP COLUMN exitStep;
+ALTER TABLE swap_orders DROP COLUMN peakPrice;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- UP
+-- on-chain mint accounts, supply is text because it may not fit a signed 64 bit integer
+CREATE TABLE mints (
+    address VARCHAR(255) PRIMARY KEY NOT NULL,
+    programId VARCHAR(255) NOT NULL,
+    decimals INTEGER NOT NULL,
+    supply TEXT NOT NULL,
+    mintAuthority VARCHAR(255) DEFAULT NULL,
+    freezeAuthority VARCHAR(255) DEFAULT NULL,
+    extensions TEXT DEFAULT NULL,
+    transferFeeBps INTEGER DEFAULT NULL,
+    transferHookProgram VARCHAR(255) DEFAULT NULL,
+    name TEXT DEFAULT NULL,
+    symbol TEXT DEFAULT NULL,
+    updatedAt DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- DOWN
+DROP TABLE mints;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- UP
+-- rug-risk score of a token and the outcome of every check
+ALTER TABLE tokens ADD riskScore REAL;
+ALTER TABLE tokens ADD riskChecks TEXT;
+ALTER TABLE tokens ADD riskCheckedAt DATETIME;
+
+-- DOWN
+ALTER TABLE tokens DROP COLUMN riskScore;
+ALTER TABLE tokens DROP COLUMN riskChecks;
+ALTER TABLE tokens DROP COLUMN riskCheckedAt;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- UP
+-- dexscreener activity of the last 5 minutes and the entry strategy that created a buy order
+ALTER TABLE market_data ADD buysM5 INTEGER;
+ALTER TABLE market_data ADD sellsM5 INTEGER;
+ALTER TABLE market_data ADD volumeM5 REAL;
+ALTER TABLE swap_orders ADD strategy VARCHAR(255);
+
+-- DOWN
+ALTER TABLE market_data DROP COLUMN buysM5;
+ALTER TABLE market_data DROP COLUMN sellsM5;
+ALTER TABLE market_data DROP COLUMN volumeM5;
+ALTER TABLE swap_orders DROP COLUMN strategy;
\ No newline at end of file
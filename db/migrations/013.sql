@@ -0,0 +1,17 @@
+-- UP
+-- paper trading, simulated orders and the balances of the simulated wallet
+ALTER TABLE swap_orders ADD simulated INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE paper_ledger (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    createdAt DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    orderId INTEGER DEFAULT NULL,
+    mint VARCHAR(255) NOT NULL,
+    delta INTEGER NOT NULL
+);
+
+CREATE INDEX paper_ledger_mint ON paper_ledger("mint");
+
+-- DOWN
+DROP TABLE paper_ledger;
+ALTER TABLE swap_orders DROP COLUMN simulated;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- UP
+-- equity curve, amounts in SOL
+CREATE TABLE portfolio_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    simulated INTEGER NOT NULL DEFAULT 0,
+    solBalance REAL NOT NULL,
+    positionsValueSol REAL NOT NULL,
+    equitySol REAL NOT NULL,
+    equityUsd REAL DEFAULT NULL,
+    costBasisSol REAL NOT NULL,
+    realizedPnlSol REAL NOT NULL,
+    unrealizedPnlSol REAL NOT NULL,
+    openPositions INTEGER NOT NULL
+);
+
+CREATE INDEX portfolio_snapshots_timestamp ON portfolio_snapshots("timestamp");
+
+-- DOWN
+DROP TABLE portfolio_snapshots;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- UP
+-- risk manager, why an order was refused and the kill switch that halts new buys
+ALTER TABLE swap_orders ADD rejectReason TEXT;
+ALTER TABLE swap_orders ADD submittedAt DATETIME;
+
+CREATE TABLE risk_state (
+    id INTEGER PRIMARY KEY NOT NULL CHECK (id = 1),
+    killSwitch INTEGER NOT NULL DEFAULT 0,
+    reason TEXT DEFAULT NULL,
+    trippedAt DATETIME DEFAULT NULL
+);
+
+-- DOWN
+DROP TABLE risk_state;
+ALTER TABLE swap_orders DROP COLUMN rejectReason;
+ALTER TABLE swap_orders DROP COLUMN submittedAt;
\ No newline at end of file
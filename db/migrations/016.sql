@@ -0,0 +1,38 @@
+-- UP
+-- order lifecycle, see db/lifecycle.go
+ALTER TABLE swap_orders ADD status VARCHAR(255) NOT NULL DEFAULT 'pending';
+ALTER TABLE swap_orders ADD attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE swap_orders ADD lastError TEXT;
+ALTER TABLE swap_orders ADD nextRetryAt DATETIME;
+
+CREATE INDEX swap_orders_status ON swap_orders("status");
+
+CREATE TABLE swap_order_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    orderId INTEGER NOT NULL,
+    createdAt DATETIME NOT NULL,
+    fromStatus VARCHAR(255) NOT NULL,
+    toStatus VARCHAR(255) NOT NULL,
+    reason TEXT DEFAULT NULL
+);
+
+CREATE INDEX swap_order_events_orderId ON swap_order_events("orderId");
+
+-- orders created before the status column derive it from their transaction, orders that already
+-- went through the lifecycle have attempts or events and keep their status
+UPDATE swap_orders SET status = CASE
+    WHEN executedAt IS NOT NULL THEN 'confirmed'
+    WHEN txStatus = 'rejected' THEN 'cancelled'
+    WHEN txStatus = 'failed' THEN 'failed'
+    WHEN txHash IS NOT NULL THEN 'submitted'
+    ELSE 'pending' END
+WHERE status = 'pending' AND attempts = 0
+AND NOT EXISTS (SELECT 1 FROM swap_order_events e WHERE e.orderId = swap_orders.id);
+
+-- DOWN
+DROP TABLE swap_order_events;
+DROP INDEX swap_orders_status;
+ALTER TABLE swap_orders DROP COLUMN status;
+ALTER TABLE swap_orders DROP COLUMN attempts;
+ALTER TABLE swap_orders DROP COLUMN lastError;
+ALTER TABLE swap_orders DROP COLUMN nextRetryAt;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- UP
+-- order leases, see db/lease.go
+ALTER TABLE swap_orders ADD leaseOwner VARCHAR(255);
+ALTER TABLE swap_orders ADD leaseExpiresAt DATETIME;
+
+-- DOWN
+ALTER TABLE swap_orders DROP COLUMN leaseOwner;
+ALTER TABLE swap_orders DROP COLUMN leaseExpiresAt;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- UP
+-- priority fees, the strategy an order asks for and the compute budget it was signed with
+ALTER TABLE swap_orders ADD feeStrategy TEXT;
+ALTER TABLE swap_orders ADD computeUnitLimit INTEGER;
+ALTER TABLE swap_orders ADD computeUnitPrice INTEGER;
+
+-- DOWN
+ALTER TABLE swap_orders DROP COLUMN feeStrategy;
+ALTER TABLE swap_orders DROP COLUMN computeUnitLimit;
+ALTER TABLE swap_orders DROP COLUMN computeUnitPrice;
\ No newline at end of file
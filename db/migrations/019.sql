@@ -0,0 +1,12 @@
+-- UP
+-- preflight simulation of the last signed transaction
+ALTER TABLE swap_orders ADD simulationLogs TEXT;
+ALTER TABLE swap_orders ADD simulationUnits INTEGER;
+ALTER TABLE swap_orders ADD simulationError TEXT;
+ALTER TABLE swap_orders ADD simulatedOutAmount INTEGER;
+
+-- DOWN
+ALTER TABLE swap_orders DROP COLUMN simulationLogs;
+ALTER TABLE swap_orders DROP COLUMN simulationUnits;
+ALTER TABLE swap_orders DROP COLUMN simulationError;
+ALTER TABLE swap_orders DROP COLUMN simulatedOutAmount;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- UP
+-- rpc log processing outcomes, logs whose transaction is not found yet are retried a few times
+ALTER TABLE rpc_logs ADD attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rpc_logs ADD skipReason TEXT;
+
+CREATE INDEX rpc_logs_attempts ON rpc_logs("attempts");
+
+-- DOWN
+DROP INDEX rpc_logs_attempts;
+ALTER TABLE rpc_logs DROP COLUMN attempts;
+ALTER TABLE rpc_logs DROP COLUMN skipReason;
@@ -0,0 +1,8 @@
+-- UP
+-- failed risk scoring attempts, a token that keeps failing is scored 0 instead of blocking the queue
+ALTER TABLE tokens ADD scoreAttempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tokens ADD scoreError TEXT;
+
+-- DOWN
+ALTER TABLE tokens DROP COLUMN scoreAttempts;
+ALTER TABLE tokens DROP COLUMN scoreError;
@@ -0,0 +1,29 @@
+-- UP
+-- one kill switch per mode, paper losses do not halt live trading and the other way round
+CREATE TABLE risk_state_by_mode (
+    simulated INTEGER PRIMARY KEY NOT NULL CHECK (simulated IN (0, 1)),
+    killSwitch INTEGER NOT NULL DEFAULT 0,
+    reason TEXT DEFAULT NULL,
+    trippedAt DATETIME DEFAULT NULL
+);
+
+-- the mode that tripped the global switch is not known, both stay halted until reset
+INSERT INTO risk_state_by_mode(simulated, killSwitch, reason, trippedAt)
+SELECT m.simulated, r.killSwitch, r.reason, r.trippedAt FROM risk_state r, (SELECT 0 AS simulated UNION ALL SELECT 1) m;
+
+DROP TABLE risk_state;
+ALTER TABLE risk_state_by_mode RENAME TO risk_state;
+
+-- DOWN
+CREATE TABLE risk_state_global (
+    id INTEGER PRIMARY KEY NOT NULL CHECK (id = 1),
+    killSwitch INTEGER NOT NULL DEFAULT 0,
+    reason TEXT DEFAULT NULL,
+    trippedAt DATETIME DEFAULT NULL
+);
+
+INSERT INTO risk_state_global(id, killSwitch, reason, trippedAt)
+SELECT 1, killSwitch, reason, trippedAt FROM risk_state ORDER BY killSwitch DESC, trippedAt DESC LIMIT 1;
+
+DROP TABLE risk_state;
+ALTER TABLE risk_state_global RENAME TO risk_state;
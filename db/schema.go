@@ -0,0 +1,197 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations/00N.sql are applied in order, the UP section of each file once per database.
+// Applied versions are recorded in schema_version, the DOWN section is kept for manual rollbacks.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// loadMigrations parses the embedded migration files, ordered by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+
+	for _, e := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".sql"))
+
+		if err != nil {
+			return nil, fmt.Errorf("migration %s is not named after its version: %w", e.Name(), err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{
+			version:    version,
+			name:       e.Name(),
+			statements: upStatements(string(content)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// upStatements returns the statements between -- UP and -- DOWN, without comment lines
+func upStatements(content string) []string {
+	up, _, _ := strings.Cut(content, "-- DOWN")
+	up = strings.TrimPrefix(strings.TrimSpace(up), "-- UP")
+
+	var lines []string
+
+	for _, line := range strings.Split(up, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); len(stmt) > 0 {
+			statements = append(statements, stmt)
+		}
+	}
+
+	return statements
+}
+
+func (s *SqlClient) appliedVersions() (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	rows, err := s.db.Query(`select version from schema_version`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+
+		err = rows.Scan(&version)
+
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, nil
+}
+
+func (s *SqlClient) tableExists(name string) bool {
+	var n int
+
+	s.db.QueryRow(`select count(*) from sqlite_master where type = 'table' and name = ?`, name).Scan(&n)
+
+	return n > 0
+}
+
+// migrate applies the migrations that are not in schema_version yet, each in its own transaction.
+// A database that predates schema_version has some of them applied already (the baseline files
+// were run by hand, later versions of the bot created tables and columns on start), for those
+// the objects that already exist are skipped once and every version is recorded.
+func (s *SqlClient) migrate() {
+	migrations, err := loadMigrations()
+
+	if err != nil {
+		log.Fatalf("migrate: Failed to load migrations: %s", err)
+	}
+
+	_, err = s.db.Exec(`create table if not exists schema_version (
+		version integer primary key not null,
+		name text not null,
+		appliedAt datetime not null
+	)`)
+
+	if err != nil {
+		log.Fatalf("migrate: Failed to create schema_version: %s", err)
+	}
+
+	applied, err := s.appliedVersions()
+
+	if err != nil {
+		log.Fatalf("migrate: Failed to read schema_version: %s", err)
+	}
+
+	legacy := len(applied) == 0 && s.tableExists("rpc_logs")
+
+	if legacy {
+		log.Println("migrate: Database predates schema_version, skipping objects that already exist")
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		err = s.applyMigration(m, legacy)
+
+		if err != nil {
+			log.Fatalf("migrate: Failed to apply %s: %s", m.name, err)
+		}
+
+		log.Printf("migrate: Applied %s \n", m.name)
+	}
+}
+
+func (s *SqlClient) applyMigration(m migration, legacy bool) error {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		_, err = tx.Exec(stmt)
+
+		if err != nil && !(legacy && alreadyApplied(err)) {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+
+	_, err = tx.Exec(`insert into schema_version(version, name, appliedAt) values(?, ?, ?)`, m.version, m.name, time.Now().UnixMilli())
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func alreadyApplied(err error) bool {
+	return strings.Contains(err.Error(), "duplicate column name") || strings.Contains(err.Error(), "already exists")
+}
@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"solana-bot/mints"
+	"solana-bot/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultConfirmationPoll    = 2 * time.Second
+	defaultConfirmationTimeout = 2 * time.Minute
+
+	// confirmed orders older than this are no longer backfilled, their transaction may be gone from the node
+	fillBackfillWindow = 24 * time.Hour
+)
+
+// awaitConfirmation polls the signature status until the transaction is confirmed, fails on-chain,
+// or its blockhash expires without the transaction landing. It gives up when the lease of the order is lost.
+func (t *Trader) awaitConfirmation(l *orderLease, txHash string, lastValidBlockHeight uint64) db.SwapConfirmation {
+
+	poll := defaultConfirmationPoll
+	timeout := defaultConfirmationTimeout
+
+	if t.c.Trader.ConfirmationPollMs > 0 {
+		poll = time.Duration(t.c.Trader.ConfirmationPollMs) * time.Millisecond
+	}
+
+	if t.c.Trader.ConfirmationTimeoutSeconds > 0 {
+		timeout = time.Duration(t.c.Trader.ConfirmationTimeoutSeconds) * time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	expired := false
+
+	for !l.Lost() {
+		statuses, err := t.h.GetSignatureStatuses([]string{txHash})
+
+		if err != nil {
+			log.Println("awaitConfirmation:", err)
+		} else if len(statuses) > 0 && statuses[0] != nil {
+			status := statuses[0]
+
+			if status.Err != nil {
+				errMessage := utils.ToString(status.Err)
+
+				return db.SwapConfirmation{
+					Status: db.TxStatusFailed,
+					Slot:   status.Slot,
+					Err:    &errMessage,
+				}
+			}
+
+			if status.ConfirmationStatus == db.TxStatusConfirmed || status.ConfirmationStatus == db.TxStatusFinalized {
+				return db.SwapConfirmation{
+					Status: status.ConfirmationStatus,
+					Slot:   status.Slot,
+				}
+			}
+
+			// processed, keep waiting
+			time.Sleep(poll)
+			continue
+		}
+
+		// the status was checked once more after the expiry, it did not land
+		if expired {
+			errMessage := "blockhash expired before the transaction landed"
+
+			return db.SwapConfirmation{
+				Status: db.TxStatusExpired,
+				Err:    &errMessage,
+			}
+		}
+
+		expired = t.isExpired(lastValidBlockHeight, deadline)
+
+		if !expired {
+			time.Sleep(poll)
+		}
+	}
+
+	return db.SwapConfirmation{}
+}
+
+// isExpired reports whether the transaction can no longer land. The expiry is decided with the
+// block height when known, otherwise with the confirmation timeout.
+func (t *Trader) isExpired(lastValidBlockHeight uint64, deadline time.Time) bool {
+	if lastValidBlockHeight == 0 {
+		return time.Now().After(deadline)
+	}
+
+	blockHeight, err := t.h.GetBlockHeight("confirmed")
+
+	if err != nil {
+		log.Println("isExpired:", err)
+
+		return false
+	}
+
+	return blockHeight > lastValidBlockHeight
+}
+
+// getLandedTransaction fetches a transaction that is known to have landed, the node serving the
+// request may lag behind the one that reported the status so it is retried a few times
+func (t *Trader) getLandedTransaction(txHash string) *helius.GetTransactionResult {
+	for attempt := 1; attempt <= 5; attempt++ {
+		tx, err := t.h.GetTransaction(txHash, "confirmed")
+
+		if err == nil && tx != nil {
+			return tx
+		}
+
+		if err != nil {
+			log.Println("getLandedTransaction:", err)
+		}
+
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+
+	return nil
+}
+
+// submittedSwap is what the confirmation tracker needs to know about a sent swap
+type submittedSwap struct {
+	Lease                *orderLease
+	OrderId              uint64
+	Attempt              int
+	TxHash               string
+	LastValidBlockHeight uint64
+	InputMint            string
+	OutputMint           string
+	QuotedOutAmount      uint64
+}
+
+// confirmSwapOrder waits for the outcome of a submitted swap and records it on the order,
+// together with the executed amounts when the swap landed
+func (t *Trader) confirmSwapOrder(s submittedSwap) db.SwapConfirmation {
+	confirmation := t.awaitConfirmation(s.Lease, s.TxHash, s.LastValidBlockHeight)
+
+	// the process that took the order over tracks the transaction now
+	if s.Lease.Lost() {
+		log.Printf("confirmSwapOrder: Order %d lease lost, stopping \n", s.OrderId)
+
+		return confirmation
+	}
+
+	log.Printf("confirmSwapOrder: Order %d tx %s is %s \n", s.OrderId, s.TxHash, confirmation.Status)
+
+	var fill *db.SwapFill
+
+	if confirmation.Status != db.TxStatusExpired {
+		tx := t.getLandedTransaction(s.TxHash)
+
+		// a confirmed order without amounts is picked up again by backfillFills
+		if tx == nil {
+			log.Printf("confirmSwapOrder: Order %d tx %s could not be fetched, the fill is backfilled later \n", s.OrderId, s.TxHash)
+		} else {
+			confirmation.Fee = tx.Meta.Fee
+
+			if confirmation.Status != db.TxStatusFailed {
+				f, err := computeFill(tx, t.w.PublicKey, s.InputMint, s.OutputMint, t.c.Solana.NativeMint, s.QuotedOutAmount)
+
+				if err != nil {
+					log.Println("confirmSwapOrder: Failed to compute fill", err)
+				} else {
+					fill = &f
+				}
+			}
+		}
+	}
+
+	err := t.db.UpdateSwapOrderConfirmation(s.OrderId, confirmation)
+
+	if err != nil {
+		log.Println("confirmSwapOrder:", err)
+	}
+
+	// an expired transaction never landed, the order can be sent again
+	if err == nil && confirmation.Status == db.TxStatusExpired {
+		t.retrySwapOrder(s.OrderId, s.Attempt, fmt.Errorf("transaction %s expired", s.TxHash))
+	}
+
+	if fill != nil {
+		log.Printf("confirmSwapOrder: Order %d filled in = %s, out = %s, quoted out = %s, slippage = %.2f bps \n",
+			s.OrderId, t.formatAmount(s.InputMint, fill.InAmount), t.formatAmount(s.OutputMint, fill.OutAmount),
+			t.formatAmount(s.OutputMint, fill.QuotedOutAmount), fill.SlippageBps)
+
+		t.db.UpdateSwapOrderFill(s.OrderId, *fill)
+	}
+
+	return confirmation
+}
+
+// backfillFills records the executed amounts of confirmed orders whose landed transaction could not be
+// fetched at confirmation time, it runs with every pending trades pass until the transaction is found
+func (t *Trader) backfillFills() {
+	for _, o := range t.db.GetUnfilledOrders(time.Now().Add(-fillBackfillWindow)) {
+		tx, err := t.h.GetTransaction(*o.TxHash, "confirmed")
+
+		if err != nil || tx == nil {
+			log.Printf("backfillFills: Order %d tx %s not available yet %v \n", o.Id, *o.TxHash, err)
+
+			continue
+		}
+
+		var quotedOutAmount uint64
+
+		if o.QuotedOutAmount != nil {
+			quotedOutAmount = *o.QuotedOutAmount
+		}
+
+		fill, err := computeFill(tx, t.w.PublicKey, o.FromToken, o.ToToken, t.c.Solana.NativeMint, quotedOutAmount)
+
+		if err != nil {
+			log.Println("backfillFills: Failed to compute fill", err)
+
+			continue
+		}
+
+		log.Printf("backfillFills: Order %d filled in = %s, out = %s \n",
+			o.Id, t.formatAmount(o.FromToken, fill.InAmount), t.formatAmount(o.ToToken, fill.OutAmount))
+
+		t.db.UpdateSwapOrderFill(o.Id, fill)
+	}
+}
+
+// formatAmount renders atomic units as whole tokens, falling back to the raw amount when the decimals are unknown
+func (t *Trader) formatAmount(mint string, amount uint64) string {
+	decimals, err := t.m.Decimals(mint)
+
+	if err != nil {
+		return strconv.FormatUint(amount, 10)
+	}
+
+	return strconv.FormatFloat(mints.ToUiAmount(amount, decimals), 'f', -1, 64)
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+// matchDetector walks the logs keeping track of the program that is currently executing and returns
+// the first detector whose pattern is logged by the detector's own program, so that a generic
+// pattern like "Instruction: Initialize" only matches inside the right program
+func matchDetector(detectors []config.DetectorConfig, logs []string) (config.DetectorConfig, bool) {
+	var stack []string
+
+	for _, line := range logs {
+		fields := strings.Fields(line)
+
+		if len(fields) >= 3 && fields[0] == "Program" {
+			if fields[2] == "invoke" {
+				stack = append(stack, fields[1])
+				continue
+			}
+
+			if fields[2] == "success" || strings.HasPrefix(fields[2], "failed") {
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+				continue
+			}
+		}
+
+		if len(stack) == 0 {
+			continue
+		}
+
+		current := stack[len(stack)-1]
+
+		for _, d := range detectors {
+			if d.ProgramId == current && strings.Contains(line, d.LogPattern) {
+				return d, true
+			}
+		}
+	}
+
+	return config.DetectorConfig{}, false
+}
+
+func (e *Engine) getDetector(name *string) (config.DetectorConfig, bool) {
+	detectors := e.config.GetDetectors()
+
+	// logs recorded before detectors existed all came from the first one
+	if name == nil {
+		return detectors[0], true
+	}
+
+	for _, d := range detectors {
+		if d.Name == *name {
+			return d, true
+		}
+	}
+
+	return config.DetectorConfig{}, false
+}
+
+func (e *Engine) isQuoteMint(mint string) bool {
+	return mint == e.config.Solana.NativeMint || mint == e.config.Solana.UsdcMint || mint == e.config.Solana.UsdtMint
+}
+
+// poolInstructions returns the detector program's instructions, including those invoked through CPI,
+// whose data starts with the detector's data prefix
+func poolInstructions(d config.DetectorConfig, tx helius.ParsedTx) []helius.Instruction {
+	prefix, err := hex.DecodeString(d.DataPrefix)
+
+	if err != nil {
+		log.Printf("poolInstructions: Invalid dataPrefix for detector %s: %s \n", d.Name, err)
+
+		return nil
+	}
+
+	var result []helius.Instruction
+
+	matches := func(inc helius.Instruction) bool {
+		if inc.ProgramId != d.ProgramId {
+			return false
+		}
+
+		if len(prefix) == 0 {
+			return true
+		}
+
+		data, err := base58.Decode(inc.Data)
+
+		return err == nil && bytes.HasPrefix(data, prefix)
+	}
+
+	for _, inc := range tx.Instructions {
+		if matches(inc.Instruction) {
+			result = append(result, inc.Instruction)
+		}
+
+		for _, inner := range inc.InnerInstructions {
+			if matches(inner) {
+				result = append(result, inner)
+			}
+		}
+	}
+
+	return result
+}
+
+// extractPool reads the pool accounts described by the rules, it fails if any configured index
+// is out of range for the instruction or if there is no base mint
+func (e *Engine) extractPool(accounts []string, rules config.AccountRules) (db.TokenEntity, bool) {
+	var token db.TokenEntity
+	ok := true
+
+	account := func(index *int) *string {
+		if index == nil {
+			return nil
+		}
+
+		if *index < 0 || *index >= len(accounts) {
+			ok = false
+
+			return nil
+		}
+
+		return &accounts[*index]
+	}
+
+	baseMint := account(rules.BaseMint)
+	token.QuoteMint = account(rules.QuoteMint)
+	token.PoolAddress = account(rules.Pool)
+	token.LpMint = account(rules.LpMint)
+	token.Creator = account(rules.Creator)
+
+	if !ok || baseMint == nil {
+		return token, false
+	}
+
+	// pools do not order their mints, the new token is the one that is not a quote mint
+	if e.isQuoteMint(*baseMint) && token.QuoteMint != nil && !e.isQuoteMint(*token.QuoteMint) {
+		baseMint, token.QuoteMint = token.QuoteMint, baseMint
+	}
+
+	token.ContractAddress = *baseMint
+
+	return token, true
+}
@@ -3,7 +3,7 @@ package engine
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"text/template"
 
@@ -14,10 +14,13 @@ import (
 	"solana-bot/db"
 	"solana-bot/dexscreener"
 	"solana-bot/helius"
+	"solana-bot/ingest"
 	"solana-bot/jupiter"
+	"solana-bot/mints"
+	"solana-bot/rpc"
+	"solana-bot/rugcheck"
 	"solana-bot/wallet"
 
-	"strings"
 	"time"
 
 	"github.com/leekchan/accounting"
@@ -26,14 +29,20 @@ import (
 type Engine struct {
 	db     *db.SqlClient
 	w      *wallet.Client
-	hs     *helius.Streamer
+	src    ingest.EventSource
 	hhc    *helius.HttpClient
+	rpc    *rpc.Client
+	mints  *mints.Service
+	rc     *rugcheck.Checker
 	ds     *dexscreener.Client
 	config *config.Config
 	j      *jupiter.Client
 	t      *Trader
 }
 
+// logs whose transaction is still not found after this many batches are skipped
+const maxLogAttempts = 10
+
 func (e *Engine) DeleteProcessedLogs() {
 	for {
 		log.Println("DeleteProcessedLogs: Running")
@@ -61,12 +70,14 @@ func (e *Engine) ProcessLogs() {
 		}
 
 		var signatures []string
+		detectorBySignature := make(map[string]*string)
 
 		for _, log := range logs {
 			signatures = append(signatures, log.Signature)
+			detectorBySignature[log.Signature] = log.Detector
 		}
 
-		txs, err := e.hhc.GetParsedTxs(signatures)
+		txs, err := e.getParsedTxs(signatures)
 
 		if err != nil {
 
@@ -76,50 +87,101 @@ func (e *Engine) ProcessLogs() {
 			continue
 		}
 
+		found := make(map[string]bool)
+
 		for _, tx := range txs {
+			found[tx.Signature] = true
 
-			for _, inc := range tx.Instructions {
-				if inc.ProgramId == e.config.LiquidityPool.RaydiumProgramId {
-					acct1, acct2 := inc.Accounts[8], inc.Accounts[9]
-					newTokenAddress := ""
+			e.processLog(tx, detectorBySignature[tx.Signature])
+		}
 
-					if acct1 == e.config.Solana.NativeMint {
-						newTokenAddress = acct2
-					} else {
-						newTokenAddress = acct1
-					}
+		// the node may not have the transaction yet, it is looked up again with the next batches
+		var missing []string
 
-					e.db.InsertNewToken(newTokenAddress, tx.Signature)
-				}
+		for _, signature := range signatures {
+			if !found[signature] {
+				missing = append(missing, signature)
 			}
-
 		}
 
+		e.db.RetryLogEvents(missing, maxLogAttempts)
+
 		// wait for 30 seconds for the next iteration
 		time.Sleep(time.Second * 30)
 	}
 
 }
 
-func (e *Engine) handleLogSubscribeMessage(message []byte) {
+// processLog inserts the tokens of the pools created by the transaction, a log that can not
+// yield a token is skipped with the reason so it is not fetched again
+func (e *Engine) processLog(tx helius.ParsedTx, detectorName *string) {
+	detector, ok := e.getDetector(detectorName)
 
-	var m helius.LogSubscribeMessage
+	if !ok {
+		log.Printf("ProcessLogs: Unknown detector for %s \n", tx.Signature)
+		e.db.SkipLogEvent(tx.Signature, "unknown detector")
 
-	err := json.Unmarshal(message, &m)
+		return
+	}
 
-	if err != nil {
-		log.Printf("Failed to Unmarshal message  %s, %s\n", string(message), err)
+	instructions := poolInstructions(detector, tx)
 
-		return
+	if len(instructions) == 0 {
+		e.db.SkipLogEvent(tx.Signature, "no matching instruction")
 
+		return
 	}
 
-	logs := m.Params.Result.Value.Logs
+	reason := "account rules not matched"
+	inserted := false
+
+	for _, inc := range instructions {
+
+		token, ok := e.extractPool(inc.Accounts, detector.Accounts)
+
+		if !ok {
+			log.Printf("ProcessLogs: %s instruction of %s does not match the account rules (%d accounts) \n",
+				detector.Name, tx.Signature, len(inc.Accounts))
+			continue
+		}
+
+		token.Detector = &detector.Name
+
+		err := e.db.InsertNewToken(token, tx.Signature)
 
-	for _, log := range logs {
-		if strings.Contains(log, e.config.LiquidityPool.MigrationMessage) {
-			e.db.InsertLog(m.Params.Result.Value.Signature)
+		if errors.Is(err, db.ErrDuplicateToken) {
+			reason = "duplicate token"
+			continue
 		}
+
+		// left unprocessed, the insert is tried again with the next batch
+		if err != nil {
+			log.Println("ProcessLogs:", err)
+
+			return
+		}
+
+		inserted = true
+	}
+
+	if !inserted {
+		e.db.SkipLogEvent(tx.Signature, reason)
+	}
+}
+
+func (e *Engine) getParsedTxs(signatures []string) ([]helius.ParsedTx, error) {
+	if e.config.Engine.TxSource == "rpc" {
+		return e.hhc.GetParsedTxsFromRPC(signatures)
+	}
+
+	return e.hhc.GetParsedTxs(signatures)
+}
+
+func (e *Engine) handleLogEvent(ev ingest.LogEvent) {
+	detector, ok := matchDetector(e.config.GetDetectors(), ev.Logs)
+
+	if ok {
+		e.db.InsertLog(ev.Signature, detector.Name)
 	}
 }
 
@@ -182,16 +244,28 @@ func (e *Engine) RefreshTokensMetadata() {
 	}
 }
 
+// RemoveScamTokens deletes the tokens that failed the risk checks, falling back to
+// the market cap and age heuristic when no minimum risk score is configured
 func (e *Engine) RemoveScamTokens() {
 
 	scamTokensConfig := e.config.Engine.RemoveScamTokens
+	minScore := e.config.Risk.MinScore
 
 	for {
 
-		log.Printf("RemoveScamTokens: Removing scam tokens where marketCap < $%v and older than %v hours \n",
-			scamTokensConfig.MinMarketCap, scamTokensConfig.MinAgeHours)
+		var scamTokens []string
+
+		if minScore > 0 {
+			log.Printf("RemoveScamTokens: Removing scam tokens where risk score < %v \n", minScore)
+
+			scamTokens = e.db.GetRiskyTokens(minScore)
+		} else {
+			log.Printf("RemoveScamTokens: Removing scam tokens where marketCap < $%v and older than %v hours \n",
+				scamTokensConfig.MinMarketCap, scamTokensConfig.MinAgeHours)
+
+			scamTokens = e.db.GetScamTokens(scamTokensConfig.MinMarketCap, scamTokensConfig.MinAgeHours)
+		}
 
-		scamTokens := e.db.GetScamTokens(scamTokensConfig.MinMarketCap, scamTokensConfig.MinAgeHours)
 		if len(scamTokens) > 0 {
 
 			e.db.DeleteTokens(scamTokens)
@@ -202,6 +276,49 @@ func (e *Engine) RemoveScamTokens() {
 
 }
 
+const (
+	defaultScoreBatchSize = 20
+
+	// tokens that still fail to score after this many passes are scored 0
+	maxScoreAttempts = 5
+)
+
+// ScoreTokens runs the rug-risk checks on newly detected tokens
+func (e *Engine) ScoreTokens() {
+	batchSize := e.config.Risk.BatchSize
+
+	if batchSize <= 0 {
+		batchSize = defaultScoreBatchSize
+	}
+
+	for {
+		tokens := e.db.GetUnscoredTokens(batchSize)
+
+		if len(tokens) > 0 {
+			log.Printf("ScoreTokens: Scoring %d tokens \n", len(tokens))
+		}
+
+		for _, t := range tokens {
+			_, _, err := e.rc.Score(t)
+
+			if err != nil {
+				log.Println("ScoreTokens:", err)
+
+				// an account that is not a mint fails the same way every time
+				attempts := maxScoreAttempts
+
+				if errors.Is(err, mints.ErrNotAMint) {
+					attempts = 1
+				}
+
+				e.db.RecordScoreFailure(t.ContractAddress, err.Error(), attempts)
+			}
+		}
+
+		time.Sleep(30 * time.Second)
+	}
+}
+
 func (e *Engine) CreateRugReport(t db.TokenEntity, m []db.MarketDataEntity) {
 
 	ac := accounting.Accounting{Symbol: "$", Precision: 2}
@@ -309,43 +426,77 @@ func (e *Engine) GetRugsReport() {
 
 func (e *Engine) Start() {
 
+	// probe rpc endpoints so failover prefers healthy, fast ones
+	go e.rpc.CheckHealth()
+
 	// start trading engine
 	go e.t.Start()
 	// process RPC Logs
 	go e.ProcessLogs()
 	go e.DeleteProcessedLogs()
 
-	// Delete scam tokens
+	// score and delete scam tokens
+	go e.ScoreTokens()
 	go e.RemoveScamTokens()
 
 	// refresh token metadata
 	go e.RefreshTopTokensMetadata()
 	go e.RefreshTokensMetadata()
 
-	// subscribe to helius websocket streaming
-	go e.hs.SubscribeToLogs()
-	go e.hs.ReadMessages()
+	// ingest program logs from the configured event source
+	err := e.src.Start()
+
+	if err != nil {
+		log.Println("Start: Failed to start event source", err)
+
+		return
+	}
 
-	for msg := range e.hs.GetMessageChannel() {
-		go e.handleLogSubscribeMessage(msg)
+	for ev := range e.src.Events() {
+		go e.handleLogEvent(ev)
 	}
 
 }
 
+func newEventSource(c *config.Config, hhc *helius.HttpClient) ingest.EventSource {
+	sc := c.Engine.EventSource
+	addresses := c.GetDetectorProgramIds()
+	scan := ingest.ScanOptions{
+		PageSize: c.Engine.Backfill.PageSize,
+		MaxPages: c.Engine.Backfill.MaxPages,
+	}
+
+	switch sc.Type {
+	case "polling":
+		interval := time.Duration(max(sc.PollIntervalSeconds, 1)) * time.Second
+
+		return ingest.NewPollingSource(hhc, addresses, interval, scan)
+	case "file":
+		return ingest.NewFileSource(sc.File, sc.ReplaySpeed)
+	default:
+		return ingest.NewWebsocketSource(helius.NewStreamer(&c.Helius, addresses), hhc, addresses, scan)
+	}
+}
+
 func New(c *config.Config) *Engine {
 
-	hhc := helius.NewHttpClient(&c.Helius)
-	hs := helius.NewStreamer(&c.Helius)
+	rc := rpc.New(&c.Rpc, &c.Helius)
+	hhc := helius.NewHttpClient(&c.Helius, rc)
 	w := wallet.New(&c.Wallet, hhc)
 	j := jupiter.New(&c.Jupiter)
 	db := db.New(c.Engine.DSN)
+	m := mints.New(hhc, db)
+	checker := rugcheck.New(&c.Risk, hhc, m, db)
 
-	t := NewTrader(w, j, hhc, c, db)
+	t := NewTrader(w, j, hhc, m, checker, c, db)
 
 	return &Engine{
 		db:     db,
-		hs:     hs,
+		src:    newEventSource(c, hhc),
 		hhc:    hhc,
+		rpc:    rc,
+		mints:  m,
+		rc:     checker,
 		config: c,
 		ds:     dexscreener.New(&c.DexScreener),
 		w:      w,
@@ -355,8 +506,20 @@ func New(c *config.Config) *Engine {
 
 }
 
+// ResetKillSwitch lets the trader buy again after the risk manager halted it, in the configured mode only
+func (e *Engine) ResetKillSwitch() {
+	simulated := e.config.Trader.Paper.Enabled
+	state := e.db.GetRiskState(simulated)
+
+	if state.KillSwitch && state.Reason != nil {
+		log.Println("ResetKillSwitch: Resetting kill switch tripped because", *state.Reason)
+	}
+
+	e.db.ResetKillSwitch(simulated)
+}
+
 func (s *Engine) Cleanup() {
-	// close db, streamer
+	// close db, event source
 	s.db.Close()
-	s.hs.Close()
+	s.src.Stop()
 }
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"log"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/strategy"
+	"solana-bot/utils"
+	"time"
+)
+
+const (
+	defaultEntryInterval = 30 * time.Second
+	defaultEntryWindow   = time.Hour
+)
+
+// runEntryStrategies checks recently detected tokens against every configured entry
+// strategy and creates a buy order for the first match of each strategy and token
+func (t *Trader) runEntryStrategies() {
+	if len(t.c.Strategies) == 0 {
+		return
+	}
+
+	interval := defaultEntryInterval
+
+	if t.c.Trader.EntryIntervalSeconds > 0 {
+		interval = time.Duration(t.c.Trader.EntryIntervalSeconds) * time.Second
+	}
+
+	// tokens older than the widest age window can not match any strategy
+	window := defaultEntryWindow
+
+	for _, s := range t.c.Strategies {
+		window = max(window, time.Duration(s.MaxAgeMinutes)*time.Minute)
+	}
+
+	for {
+		tokens := t.db.GetRecentTokens(time.Now().Add(-window))
+
+		for _, token := range tokens {
+			m := t.db.GetLatestMarketData(token.ContractAddress)
+
+			for _, s := range t.c.Strategies {
+				t.evaluateEntry(s, token, m)
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (t *Trader) evaluateEntry(s config.EntryStrategyConfig, token db.TokenEntity, m *db.MarketDataEntity) {
+
+	if s.QuantitySol <= 0 || t.db.HasStrategyOrder(s.Name, token.ContractAddress, t.c.Trader.Paper.Enabled) {
+		return
+	}
+
+	reason, ok := strategy.EvaluateEntry(s, token, m, time.Now())
+
+	if !ok {
+		return
+	}
+
+	committed := t.db.GetCommittedSol(token.ContractAddress, t.c.Trader.Paper.Enabled)
+
+	if s.MaxSolPerToken > 0 && committed+float64(s.QuantitySol) > float64(s.MaxSolPerToken) {
+		log.Printf("evaluateEntry: %s skips %s, %.4f SOL already committed to the token \n", s.Name, token.ContractAddress, committed)
+
+		return
+	}
+
+	total := t.db.GetCommittedSol("", t.c.Trader.Paper.Enabled)
+
+	if s.MaxSolTotal > 0 && total+float64(s.QuantitySol) > float64(s.MaxSolTotal) {
+		log.Printf("evaluateEntry: %s skips %s, %.4f SOL already committed in total \n", s.Name, token.ContractAddress, total)
+
+		return
+	}
+
+	log.Printf("evaluateEntry: %s buys %s, %s \n", s.Name, token.ContractAddress, reason)
+
+	amountDetails := utils.ToString(db.AmountDetails{QuantitySol: s.QuantitySol})
+
+	buy := db.SwapTradeEntity{
+		FromToken:     t.c.Solana.NativeMint,
+		ToToken:       token.ContractAddress,
+		AmountDetails: &amountDetails,
+		TriggerReason: &reason,
+		Strategy:      &s.Name,
+		Simulated:     t.c.Trader.Paper.Enabled,
+	}
+
+	if s.PriorityFee != nil {
+		feeStrategy := utils.ToString(*s.PriorityFee)
+		buy.FeeStrategy = &feeStrategy
+	}
+
+	if len(s.ExitRules) > 0 {
+		rules := string(s.ExitRules)
+		buy.Rules = &rules
+	}
+
+	id, err := t.db.InsertSwapOrder(buy)
+
+	if err != nil {
+		return
+	}
+
+	buy.Id = id
+
+	go t.executeTrade(buy)
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/jupiter"
+	"solana-bot/utils"
+)
+
+// priority fee modes, see config.PriorityFeeConfig
+const (
+	PriorityFeeFixed      = "fixed"      // a fixed compute unit price
+	PriorityFeeAuto       = "auto"       // jupiter's estimate, capped
+	PriorityFeePercentile = "percentile" // a percentile of the recent fees paid for the pool accounts
+)
+
+const (
+	defaultFeePercentile = 75
+	defaultPriorityLevel = "veryHigh"
+)
+
+// feeStrategy returns the fee strategy of an order, orders without one use the trader's
+func (t *Trader) feeStrategy(tr db.SwapTradeEntity) config.PriorityFeeConfig {
+	if tr.FeeStrategy != nil {
+		return utils.Deserialize[config.PriorityFeeConfig](*tr.FeeStrategy)
+	}
+
+	return t.c.Trader.PriorityFee
+}
+
+// priorityFee resolves a fee strategy into the priority fee of the swap transaction
+func (t *Trader) priorityFee(c config.PriorityFeeConfig, quote *jupiter.GetQuoteResponse) jupiter.PriorityFee {
+	switch c.Mode {
+	case "":
+		return jupiter.PriorityFee{}
+	case PriorityFeeFixed:
+		return jupiter.PriorityFee{ComputeUnitPriceMicroLamports: c.MicroLamports}
+	case PriorityFeeAuto:
+		level := c.PriorityLevel
+
+		if len(level) == 0 {
+			level = defaultPriorityLevel
+		}
+
+		return jupiter.PriorityFee{MaxLamports: c.MaxLamports, PriorityLevel: level}
+	case PriorityFeePercentile:
+		price, err := t.estimatePriorityFee(c, quote)
+
+		if err != nil {
+			log.Printf("priorityFee: %s, using %d micro-lamports \n", err, c.MicroLamports)
+
+			price = c.MicroLamports
+		}
+
+		return jupiter.PriorityFee{ComputeUnitPriceMicroLamports: price}
+	}
+
+	log.Printf("priorityFee: Unknown mode %s, using jupiter's default \n", c.Mode)
+
+	return jupiter.PriorityFee{}
+}
+
+// estimatePriorityFee is a percentile of the compute unit prices paid in recent slots by transactions
+// that write-locked the pools of the route
+func (t *Trader) estimatePriorityFee(c config.PriorityFeeConfig, quote *jupiter.GetQuoteResponse) (uint64, error) {
+	var accounts []string
+
+	for _, r := range quote.RoutePlan {
+		if !slices.Contains(accounts, r.SwapInfo.AmmKey) {
+			accounts = append(accounts, r.SwapInfo.AmmKey)
+		}
+	}
+
+	fees, err := t.h.GetRecentPrioritizationFees(accounts)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(fees) == 0 {
+		return 0, errors.New("no recent prioritization fees for the pool accounts")
+	}
+
+	prices := make([]uint64, len(fees))
+
+	for i, f := range fees {
+		prices[i] = f.PrioritizationFee
+	}
+
+	slices.Sort(prices)
+
+	percentile := c.Percentile
+
+	if percentile <= 0 || percentile > 100 {
+		percentile = defaultFeePercentile
+	}
+
+	price := prices[max(int(math.Ceil(percentile/100*float64(len(prices))))-1, 0)]
+
+	if c.MaxMicroLamports > 0 {
+		price = min(price, c.MaxMicroLamports)
+	}
+
+	return price, nil
+}
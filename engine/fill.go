@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"strconv"
+)
+
+const lamportsPerSignature = 5000
+
+// computeFill derives what a confirmed swap exchanged from the balance changes of the wallet.
+// Token amounts come from the pre/post token balances owned by the wallet. SOL is the lamport
+// change of the wallet with the transaction fee and the rent of token accounts opened by the swap
+// added back, plus any wrapped SOL left in a token account.
+func computeFill(tx *helius.GetTransactionResult, wallet, inputMint, outputMint, nativeMint string, quotedOutAmount uint64) (db.SwapFill, error) {
+	fill := db.SwapFill{QuotedOutAmount: quotedOutAmount}
+
+	keys, signatures, err := helius.TransactionKeys(tx)
+
+	if err != nil {
+		return fill, err
+	}
+
+	walletIndex := slices.Index(keys, wallet)
+	meta := tx.Meta
+
+	if walletIndex < 0 || walletIndex >= len(meta.PreBalances) || walletIndex >= len(meta.PostBalances) {
+		return fill, fmt.Errorf("computeFill: wallet %s is not part of the transaction", wallet)
+	}
+
+	deltas := make(map[string]int64)
+	existing := make(map[int]bool)
+
+	for _, b := range meta.PreTokenBalances {
+		existing[b.AccountIndex] = true
+
+		if b.Owner == wallet {
+			deltas[b.Mint] -= parseAmount(b.UiTokenAmount.Amount)
+		}
+	}
+
+	var rent int64
+
+	for _, b := range meta.PostTokenBalances {
+		if b.Owner != wallet {
+			continue
+		}
+
+		amount := parseAmount(b.UiTokenAmount.Amount)
+		deltas[b.Mint] += amount
+
+		// a token account opened by the swap holds rent that is not part of the trade
+		if !existing[b.AccountIndex] && b.AccountIndex < len(meta.PostBalances) {
+			rent += int64(meta.PostBalances[b.AccountIndex])
+
+			if b.Mint == nativeMint {
+				rent -= amount
+			}
+		}
+	}
+
+	deltas[nativeMint] += int64(meta.PostBalances[walletIndex]) - int64(meta.PreBalances[walletIndex]) + int64(meta.Fee) + rent
+
+	fill.InAmount = uint64(max(-deltas[inputMint], 0))
+	fill.OutAmount = uint64(max(deltas[outputMint], 0))
+
+	baseFee := uint64(signatures) * lamportsPerSignature
+	fill.NetworkFee = min(meta.Fee, baseFee)
+	fill.PriorityFee = meta.Fee - fill.NetworkFee
+
+	if quotedOutAmount > 0 {
+		fill.SlippageBps = (float64(quotedOutAmount) - float64(fill.OutAmount)) / float64(quotedOutAmount) * 10000
+	}
+
+	return fill, nil
+}
+
+func parseAmount(amount string) int64 {
+	val, _ := strconv.ParseInt(amount, 10, 64)
+
+	return val
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"testing"
+)
+
+const (
+	fillWallet = "6CAzXCHV6SrrcD9kn31gzgjGajqffrEwtg7yyPML47aG"
+	fillMint   = "4MEamVEVz9dXwDVVDdYDkBN2kt8toE2nWVygNwdCayhZ"
+)
+
+func loadTransaction(t *testing.T, path string) *helius.GetTransactionResult {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result helius.GetTransactionResult
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return &result
+}
+
+// The buy pays 0.1 SOL and a 20000 lamport priority fee and opens the token account of the wallet,
+// wrapped SOL goes through a temporary account closed in the same transaction. The sell swaps part of
+// the tokens back without a priority fee.
+func TestComputeFill(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		inputMint  string
+		outputMint string
+		quoted     uint64
+		want       db.SwapFill
+	}{
+		{
+			name:       "buy",
+			path:       "testdata/swap_buy_tx.json",
+			inputMint:  nativeMint,
+			outputMint: fillMint,
+			quoted:     125_000_000,
+			want: db.SwapFill{
+				InAmount:        100_000_000,
+				OutAmount:       123_456_789,
+				QuotedOutAmount: 125_000_000,
+				NetworkFee:      5000,
+				PriorityFee:     20_000,
+				SlippageBps:     123.456888,
+			},
+		},
+		{
+			name:       "sell",
+			path:       "testdata/swap_sell_tx.json",
+			inputMint:  fillMint,
+			outputMint: nativeMint,
+			quoted:     50_250_000,
+			want: db.SwapFill{
+				InAmount:        100_000_000,
+				OutAmount:       50_000_000,
+				QuotedOutAmount: 50_250_000,
+				NetworkFee:      5000,
+				SlippageBps:     49.751244,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill, err := computeFill(loadTransaction(t, tt.path), fillWallet, tt.inputMint, tt.outputMint, nativeMint, tt.quoted)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if math.Abs(fill.SlippageBps-tt.want.SlippageBps) > 1e-4 {
+				t.Errorf("slippage = %f bps, want %f", fill.SlippageBps, tt.want.SlippageBps)
+			}
+
+			fill.SlippageBps = tt.want.SlippageBps
+
+			if fill != tt.want {
+				t.Errorf("fill = %+v, want %+v", fill, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeFillUnknownWallet(t *testing.T) {
+	tx := loadTransaction(t, "testdata/swap_buy_tx.json")
+
+	if _, err := computeFill(tx, nativeMint, nativeMint, fillMint, nativeMint, 0); err == nil {
+		t.Error("computeFill succeeded for a wallet that is not part of the transaction")
+	}
+}
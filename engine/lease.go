@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"solana-bot/db"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLeaseTtl = time.Minute
+
+// leaseOwner identifies this process in the leases it holds, each lease adds a sequence number
+func leaseOwner() string {
+	host, err := os.Hostname()
+
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+func (t *Trader) leaseTtl() time.Duration {
+	if t.c.Trader.LeaseSeconds > 0 {
+		return time.Duration(t.c.Trader.LeaseSeconds) * time.Second
+	}
+
+	return defaultLeaseTtl
+}
+
+// orderLease is a lease held on an order. It is lost when the heartbeat finds another owner took
+// the order over, or could not renew it before it expired, the order must not be executed further then.
+// Every acquisition has its own owner, so two goroutines of the same process never share a lease.
+type orderLease struct {
+	id    uint64
+	owner string
+	done  chan struct{}
+	lost  atomic.Bool
+}
+
+func (l *orderLease) Lost() bool {
+	return l.lost.Load()
+}
+
+// acquireLease claims an order in the database so that no other goroutine or process executes it.
+// The lease is renewed in the background until it is released, when the process dies it
+// expires and the order is recovered by recoverOrders.
+func (t *Trader) acquireLease(id uint64) (*orderLease, bool) {
+	ttl := t.leaseTtl()
+
+	owner := fmt.Sprintf("%s-%d", t.owner, t.leases.Add(1))
+
+	if !t.db.AcquireSwapOrderLease(id, owner, ttl) {
+		return nil, false
+	}
+
+	l := &orderLease{id: id, owner: owner, done: make(chan struct{})}
+
+	go t.heartbeat(l, ttl)
+
+	return l, true
+}
+
+func (t *Trader) heartbeat(l *orderLease, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			err := t.db.RenewSwapOrderLease(l.id, l.owner, ttl)
+
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+
+			log.Println("heartbeat:", err)
+
+			if errors.Is(err, db.ErrLeaseLost) || time.Since(renewed) >= ttl {
+				log.Printf("heartbeat: Order %d lease lost, stopping \n", l.id)
+				l.lost.Store(true)
+
+				return
+			}
+		}
+	}
+}
+
+func (t *Trader) releaseLease(l *orderLease) {
+	close(l.done)
+	t.db.ReleaseSwapOrderLease(l.id, l.owner)
+}
+
+// recoverOrders picks up the orders a stopped process left in flight once their lease expired, it runs
+// with every pending trades pass so orders of a process restarted within the lease are not missed.
+// Signed transactions are looked up on-chain first, an order is only sent again once its transaction
+// can no longer land.
+func (t *Trader) recoverOrders() {
+	orders := t.db.GetInterruptedOrders(t.c.Trader.Paper.Enabled)
+
+	if len(orders) > 0 {
+		log.Printf("recoverOrders: Found %d interrupted orders \n", len(orders))
+	}
+
+	for _, o := range orders {
+		go t.recoverOrder(o)
+	}
+}
+
+func (t *Trader) recoverOrder(o db.SwapTradeEntity) {
+	l, ok := t.acquireLease(o.Id)
+
+	if !ok {
+		return
+	}
+
+	defer t.releaseLease(l)
+
+	// the transaction expired without landing, the retry was not scheduled yet
+	if o.Status == db.OrderStatusExpired {
+		t.retrySwapOrder(o.Id, o.Attempts, errors.New("interrupted after the transaction expired"))
+
+		return
+	}
+
+	if o.TxHash == nil {
+		t.retrySwapOrder(o.Id, o.Attempts, errors.New("interrupted before the transaction was signed"))
+
+		return
+	}
+
+	var lastValidBlockHeight, quotedOutAmount uint64
+
+	if o.LastValidBlockHeight != nil {
+		lastValidBlockHeight = *o.LastValidBlockHeight
+	}
+
+	if o.QuotedOutAmount != nil {
+		quotedOutAmount = *o.QuotedOutAmount
+	}
+
+	// signed but stopped before the order recorded the submission, the transaction may be out
+	if o.Status == db.OrderStatusQuoting {
+		err := t.db.SubmitSwapOrder(o.Id, *o.TxHash, lastValidBlockHeight, quotedOutAmount)
+
+		if err != nil {
+			log.Println("recoverOrder:", err)
+
+			return
+		}
+	}
+
+	log.Printf("recoverOrder: Order %d checking %s on-chain \n", o.Id, *o.TxHash)
+
+	t.confirmSwapOrder(submittedSwap{
+		Lease:                l,
+		OrderId:              o.Id,
+		Attempt:              o.Attempts,
+		TxHash:               *o.TxHash,
+		LastValidBlockHeight: lastValidBlockHeight,
+		InputMint:            o.FromToken,
+		OutputMint:           o.ToToken,
+		QuotedOutAmount:      quotedOutAmount,
+	})
+}
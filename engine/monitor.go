@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"solana-bot/db"
+	"solana-bot/jupiter"
+	"solana-bot/mints"
+	"solana-bot/strategy"
+	"solana-bot/utils"
+	"strconv"
+	"time"
+)
+
+const defaultMonitorInterval = 15 * time.Second
+
+// monitorPositions evaluates the exit rules of every open position and creates
+// sell orders for the ladder steps, take profits, stop losses and trailing stops that were crossed
+func (t *Trader) monitorPositions() {
+	interval := defaultMonitorInterval
+
+	if t.c.Trader.MonitorIntervalSeconds > 0 {
+		interval = time.Duration(t.c.Trader.MonitorIntervalSeconds) * time.Second
+	}
+
+	for {
+		positions := t.db.GetOpenPositions(t.c.Trader.Paper.Enabled)
+
+		for _, p := range positions {
+			t.evaluatePosition(p)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (t *Trader) evaluatePosition(p db.SwapTradeEntity) {
+
+	rules := utils.Deserialize[db.SwapRules](*p.Rules)
+
+	if rules.TakeProfit == 0 && rules.StopLoss == 0 && len(rules.Ladder) == 0 && rules.TrailingStop == 0 {
+		return
+	}
+
+	mint := p.ToToken
+
+	holding, err := t.bal.GetTokenHolding(mint)
+
+	if err != nil {
+		log.Println("evaluatePosition:", err)
+
+		return
+	}
+
+	if holding.Amount == 0 {
+		log.Printf("evaluatePosition: Position %d has no %s balance left \n", p.Id, mint)
+
+		return
+	}
+
+	decimals, err := t.m.Decimals(mint)
+
+	if err != nil {
+		log.Println("evaluatePosition:", err)
+
+		return
+	}
+
+	sold, err := t.db.GetSoldAmount(p.Id)
+
+	if err != nil {
+		log.Println("evaluatePosition:", err)
+
+		return
+	}
+
+	if sold >= *p.OutAmount {
+		log.Printf("evaluatePosition: Position %d was sold already \n", p.Id)
+
+		return
+	}
+
+	done := make(map[int]bool)
+
+	// exits that are still pending, quoting or submitted will sell their quantity, it is not left to sell again
+	var selling uint64
+
+	for _, o := range t.db.GetExitOrders(p.Id) {
+		if o.ExitStep != nil {
+			done[*o.ExitStep] = true
+		}
+
+		if o.Status != db.OrderStatusConfirmed && o.AmountDetails != nil {
+			selling += mints.ToAtomic(utils.Deserialize[db.AmountDetails](*o.AmountDetails).QuantityToken, decimals)
+		}
+	}
+
+	if sold+selling >= *p.OutAmount {
+		log.Printf("evaluatePosition: Position %d is being sold already \n", p.Id)
+
+		return
+	}
+
+	// the position is what this buy got minus what its exits sold or are selling, other holdings of the mint are left alone
+	remaining := min(*p.OutAmount-sold-selling, uint64(holding.Amount))
+
+	entryPrice := toPrice(*p.InAmount, *p.OutAmount, decimals)
+	price, source := t.getLivePrice(mint, remaining, decimals)
+
+	if price <= 0 {
+		return
+	}
+
+	peakPrice := max(entryPrice, price)
+
+	if p.PeakPrice != nil {
+		peakPrice = max(peakPrice, *p.PeakPrice)
+	}
+
+	if p.PeakPrice == nil || peakPrice > *p.PeakPrice {
+		t.db.UpdatePeakPrice(p.Id, peakPrice)
+	}
+
+	if step, reason, ok := strategy.EvaluateLadder(rules, entryPrice, price, done); ok {
+
+		// ladder fractions are of the bought amount, not of what is left
+		quantity := float64(rules.Ladder[step].Fraction) * mints.ToUiAmount(*p.OutAmount, decimals)
+		amountDetails := utils.ToString(db.AmountDetails{QuantityToken: quantity})
+
+		t.createExitOrder(p, fmt.Sprintf("%s (%s)", reason, source), &amountDetails, &step)
+
+		return
+	}
+
+	reason, triggered := strategy.EvaluateExit(rules, entryPrice, price)
+
+	// the trailing stop covers whatever the ladder has not sold yet
+	if !triggered && len(done) >= len(rules.Ladder) {
+		reason, triggered = strategy.EvaluateTrailingStop(rules, peakPrice, price)
+	}
+
+	if !triggered {
+		return
+	}
+
+	amountDetails := utils.ToString(db.AmountDetails{QuantityToken: mints.ToUiAmount(remaining, decimals)})
+
+	t.createExitOrder(p, fmt.Sprintf("%s (%s)", reason, source), &amountDetails, nil)
+}
+
+// createExitOrder inserts a sell order linked to the position and executes it right away
+func (t *Trader) createExitOrder(p db.SwapTradeEntity, reason string, amountDetails *string, exitStep *int) {
+
+	log.Printf("evaluatePosition: Position %d %s \n", p.Id, reason)
+
+	sell := db.SwapTradeEntity{
+		FromToken:     p.ToToken,
+		ToToken:       t.c.Solana.NativeMint,
+		AmountDetails: amountDetails,
+		ParentId:      &p.Id,
+		TriggerReason: &reason,
+		ExitStep:      exitStep,
+		FeeStrategy:   p.FeeStrategy,
+		Simulated:     t.c.Trader.Paper.Enabled,
+	}
+
+	id, err := t.db.InsertSwapOrder(sell)
+
+	if err != nil {
+		return
+	}
+
+	sell.Id = id
+
+	// stop losses can not wait for the next pending trades run
+	go t.executeTrade(sell)
+}
+
+// getLivePrice returns the price in SOL per token, preferably what selling the position would return
+// according to Jupiter, otherwise the latest market data snapshot
+func (t *Trader) getLivePrice(mint string, amount uint64, decimals int) (float64, string) {
+
+	quote := t.j.GetQuote(jupiter.GetQuoteParams{
+		InputMint:   mint,
+		OutputMint:  t.c.Solana.NativeMint,
+		Amount:      float64(amount),
+		SlippageBps: t.c.Jupiter.SlippageBps,
+	})
+
+	if quote != nil {
+		outAmount, err := strconv.ParseUint(quote.OutAmount, 10, 64)
+
+		if err == nil && outAmount > 0 {
+			return toPrice(outAmount, amount, decimals), "jupiter quote"
+		}
+	}
+
+	m := t.db.GetLatestMarketData(mint)
+
+	if m == nil {
+		return 0, "no price"
+	}
+
+	return m.PriceNative, "market data"
+}
+
+// toPrice converts a lamports for tokens exchange into SOL per token
+func toPrice(lamports uint64, tokenAmount uint64, decimals int) float64 {
+	if tokenAmount == 0 {
+		return 0
+	}
+
+	return mints.ToUiAmount(lamports, 9) / mints.ToUiAmount(tokenAmount, decimals)
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"solana-bot/jupiter"
+	"solana-bot/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const defaultMaxDeviationBps = 100
+
+// errBlockhashNotFound means the simulation did not know the blockhash of the transaction,
+// it has to be rebuilt with a fresh one, see Trader.swap
+var errBlockhashNotFound = errors.New("blockhash not found")
+
+// preflightSwap simulates a signed swap before it is sent and records the outcome on the order. It fails
+// when the simulation fails or the output it credits to the wallet falls short of the quote by more
+// than the allowed deviation.
+func (t *Trader) preflightSwap(orderId uint64, signedTx string, quote *jupiter.GetQuoteResponse, swapTx *jupiter.BuildSwapTransactionResponseBody) error {
+	native := quote.OutputMint == t.c.Solana.NativeMint
+	account := t.w.PublicKey
+
+	if !native {
+		ata, err := t.associatedTokenAccount(quote.OutputMint)
+
+		if err != nil {
+			return fmt.Errorf("preflightSwap: %w", err)
+		}
+
+		account = ata
+	}
+
+	before, err := t.simulatedBalance(account, native)
+
+	if err != nil {
+		return fmt.Errorf("preflightSwap: failed to get balance of %s: %w", account, err)
+	}
+
+	result, err := t.h.SimulateTransaction(signedTx, []string{account})
+
+	if err != nil {
+		return fmt.Errorf("preflightSwap: %w", err)
+	}
+
+	sim := db.SwapSimulation{Logs: result.Value.Logs}
+
+	if result.Value.UnitsConsumed != nil {
+		sim.UnitsConsumed = *result.Value.UnitsConsumed
+	}
+
+	if result.Value.Err != nil {
+		failure := fmt.Sprintf("simulation failed: %s", utils.ToString(result.Value.Err))
+		sim.Err = &failure
+
+		t.db.RecordSwapSimulation(orderId, sim)
+		log.Printf("preflightSwap: Order %d %s \n%s \n", orderId, failure, strings.Join(result.Value.Logs, "\n"))
+
+		if strings.Contains(failure, "BlockhashNotFound") {
+			return fmt.Errorf("preflightSwap: order %d %w", orderId, errBlockhashNotFound)
+		}
+
+		return fmt.Errorf("preflightSwap: order %d %s", orderId, failure)
+	}
+
+	var after uint64
+
+	if len(result.Value.Accounts) > 0 && result.Value.Accounts[0] != nil {
+		after, err = accountBalance(result.Value.Accounts[0], native)
+
+		if err != nil {
+			return fmt.Errorf("preflightSwap: %w", err)
+		}
+	}
+
+	// the transaction fees come out of the lamports that receive a native output, the wallet is the only signer
+	if native {
+		after += lamportsPerSignature + uint64(max(swapTx.PrioritizationFeeLamports, 0))
+	}
+
+	if after > before {
+		sim.OutAmount = after - before
+	}
+
+	quotedOutAmount, _ := strconv.ParseUint(quote.OutAmount, 10, 64)
+
+	maxDeviationBps := t.c.Trader.Simulation.MaxDeviationBps
+
+	if maxDeviationBps <= 0 {
+		maxDeviationBps = t.c.Jupiter.SlippageBps
+	}
+
+	if maxDeviationBps <= 0 {
+		maxDeviationBps = defaultMaxDeviationBps
+	}
+
+	if quotedOutAmount > 0 {
+		deviationBps := (float64(quotedOutAmount) - float64(sim.OutAmount)) / float64(quotedOutAmount) * 10000
+
+		if deviationBps > float64(maxDeviationBps) {
+			failure := fmt.Sprintf("simulated output %d is %.0f bps below the quoted %d, at most %d allowed", sim.OutAmount, deviationBps, quotedOutAmount, maxDeviationBps)
+			sim.Err = &failure
+
+			t.db.RecordSwapSimulation(orderId, sim)
+
+			return fmt.Errorf("preflightSwap: order %d %s", orderId, failure)
+		}
+	}
+
+	t.db.RecordSwapSimulation(orderId, sim)
+
+	log.Printf("preflightSwap: Order %d simulated, out = %d, quoted out = %d, units = %d \n", orderId, sim.OutAmount, quotedOutAmount, sim.UnitsConsumed)
+
+	return nil
+}
+
+// associatedTokenAccount is the wallet's token account of a mint, for either token program
+func (t *Trader) associatedTokenAccount(mint string) (string, error) {
+	m, err := t.m.Get(mint)
+
+	if err != nil {
+		return "", err
+	}
+
+	owner := solana.MustPublicKeyFromBase58(t.w.PublicKey)
+	program := solana.MustPublicKeyFromBase58(m.ProgramId)
+	mintKey := solana.MustPublicKeyFromBase58(mint)
+
+	ata, _, err := solana.FindProgramAddress([][]byte{owner[:], program[:], mintKey[:]}, solana.SPLAssociatedTokenAccountProgramID)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ata.String(), nil
+}
+
+// simulatedBalance is the current balance of the account the swap output is credited to,
+// lamports of the wallet or the amount of the token account, 0 when it does not exist yet
+func (t *Trader) simulatedBalance(account string, native bool) (uint64, error) {
+	if native {
+		bal, err := t.h.GetBalance(account)
+
+		return uint64(bal), err
+	}
+
+	info, data, err := t.h.GetAccountInfo(account)
+
+	if err != nil || info == nil {
+		return 0, err
+	}
+
+	return tokenAccountAmount(data)
+}
+
+func accountBalance(info *helius.AccountInfo, native bool) (uint64, error) {
+	if native {
+		return info.Lamports, nil
+	}
+
+	if len(info.Data) == 0 {
+		return 0, fmt.Errorf("simulated account has no data")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(info.Data[0])
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tokenAccountAmount(data)
+}
+
+// tokenAccountAmount reads the amount of an spl token account: mint (32), owner (32), amount (u64)
+func tokenAccountAmount(data []byte) (uint64, error) {
+	if len(data) < 72 {
+		return 0, fmt.Errorf("token account data too short: %d bytes", len(data))
+	}
+
+	return binary.LittleEndian.Uint64(data[64:72]), nil
+}
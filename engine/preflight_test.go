@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"solana-bot/jupiter"
+	"solana-bot/rpc"
+	"solana-bot/wallet"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// rpcNode is a stand-in for the getBalance and simulateTransaction methods of an rpc node
+type rpcNode struct {
+	balance    int
+	simulation string // value of the simulateTransaction result
+	simulated  int
+}
+
+func (n *rpcNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int    `json:"id"`
+		Method string `json:"method"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+
+	switch req.Method {
+	case "getBalance":
+		result = map[string]interface{}{"context": map[string]int{"slot": 100}, "value": n.balance}
+	case "simulateTransaction":
+		n.simulated++
+		result = map[string]interface{}{"context": map[string]int{"slot": 100}, "value": json.RawMessage(n.simulation)}
+	default:
+		http.Error(w, "unknown method "+req.Method, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+// newPreflightTrader returns a trader selling for SOL against the stand-in node, and the path of its database
+func newPreflightTrader(t *testing.T, node *rpcNode) (*Trader, string) {
+	t.Helper()
+
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	d := db.New(path)
+	t.Cleanup(d.Close)
+
+	key := solana.NewWallet().PrivateKey
+
+	tr := &Trader{
+		c:  testConfig(),
+		db: d,
+		h:  helius.NewHttpClient(&config.HeliusConfig{}, rpc.NewEndpoint(server.URL, 1000)),
+		w:  wallet.New(&config.WalletConfig{PrivKey: key.String(), Pubkey: key.PublicKey().String()}, nil),
+	}
+
+	return tr, path
+}
+
+// simulationError reads what preflightSwap recorded on the order
+func simulationError(t *testing.T, path string, id uint64) *string {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	var failure *string
+
+	if err := conn.QueryRow(`select simulationError from swap_orders where id = ?`, id).Scan(&failure); err != nil {
+		t.Fatal(err)
+	}
+
+	return failure
+}
+
+func TestPreflightSwap(t *testing.T) {
+	tests := []struct {
+		name       string
+		simulation string
+		wantErr    string // part of the error, empty when the swap may be sent
+		blockhash  bool   // the error is errBlockhashNotFound
+	}{
+		{
+			name:       "credits the quote",
+			simulation: `{"err":null,"logs":["Program log: ok"],"unitsConsumed":120000,"accounts":[{"data":["","base64"],"owner":"11111111111111111111111111111111","lamports":1099990000,"executable":false}]}`,
+		},
+		{
+			name:       "credits too little",
+			simulation: `{"err":null,"logs":[],"unitsConsumed":120000,"accounts":[{"data":["","base64"],"owner":"11111111111111111111111111111111","lamports":1050000000,"executable":false}]}`,
+			wantErr:    "below the quoted",
+		},
+		{
+			name:       "fails",
+			simulation: `{"err":{"InstructionError":[2,{"Custom":6001}]},"logs":["Program log: slippage exceeded"],"unitsConsumed":80000,"accounts":[null]}`,
+			wantErr:    "simulation failed",
+		},
+		{
+			name:       "unknown blockhash",
+			simulation: `{"err":"BlockhashNotFound","logs":[],"unitsConsumed":0,"accounts":[null]}`,
+			wantErr:    "blockhash not found",
+			blockhash:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &rpcNode{balance: 1_000_000_000, simulation: tt.simulation}
+			tr, path := newPreflightTrader(t, node)
+
+			id, err := tr.db.InsertSwapOrder(db.SwapTradeEntity{FromToken: fillMint, ToToken: nativeMint})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// a sell for 0.1 SOL, the simulation pays 5000 lamports of fees out of the output
+			quote := &jupiter.GetQuoteResponse{InputMint: fillMint, OutputMint: nativeMint, OutAmount: "100000000"}
+
+			err = tr.preflightSwap(id, "signed", quote, &jupiter.BuildSwapTransactionResponseBody{})
+
+			if node.simulated != 1 {
+				t.Fatalf("simulated %d times, want once", node.simulated)
+			}
+
+			failure := simulationError(t, path, id)
+
+			if len(tt.wantErr) == 0 {
+				if err != nil || failure != nil {
+					t.Fatalf("preflightSwap = %v, recorded %v, want the swap to be sent", err, failure)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("preflightSwap = %v, want an error with %q", err, tt.wantErr)
+			}
+
+			if errors.Is(err, errBlockhashNotFound) != tt.blockhash {
+				t.Errorf("errors.Is(%v, errBlockhashNotFound) = %t, want %t", err, !tt.blockhash, tt.blockhash)
+			}
+
+			if failure == nil {
+				t.Error("the failed simulation was not recorded on the order")
+			}
+		})
+	}
+}
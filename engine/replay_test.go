@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"solana-bot/config"
+	"solana-bot/helius"
+	"solana-bot/ingest"
+	"testing"
+)
+
+const (
+	raydiumProgramId = "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"
+	nativeMint       = "So11111111111111111111111111111111111111112"
+
+	fixtureSignature = "VSqHpRyJXtaTSBWdEq8co1BQ7rdjcUt41y2P42PqYhCvxD5uhXKrBnZ9ZQr2bBv2zDuFicKudooE8P3G1ceTrsF"
+)
+
+func testConfig() *config.Config {
+	var c config.Config
+
+	c.LiquidityPool.RaydiumProgramId = raydiumProgramId
+	c.LiquidityPool.MigrationMessage = "initialize2: InitializeInstruction2"
+	c.Solana.NativeMint = nativeMint
+
+	return &c
+}
+
+// replay feeds a capture file through the file source and returns the events it emitted
+func replay(t *testing.T, path string) []ingest.LogEvent {
+	t.Helper()
+
+	src := ingest.NewFileSource(path, 0)
+
+	if err := src.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []ingest.LogEvent
+
+	for ev := range src.Events() {
+		events = append(events, ev)
+	}
+
+	return events
+}
+
+// The capture holds the subscription confirmation, a swap of the raydium program, the initialize2
+// pattern logged by another program and a real initialize2. Only the last one is a new pool.
+func TestReplayDetectsRaydiumPool(t *testing.T) {
+	c := testConfig()
+	e := &Engine{config: c}
+
+	events := replay(t, "testdata/raydium_initialize2.ndjson")
+
+	if len(events) != 3 {
+		t.Fatalf("replayed %d events, want the 3 log notifications", len(events))
+	}
+
+	var matched []ingest.LogEvent
+
+	for _, ev := range events {
+		if _, ok := matchDetector(c.GetDetectors(), ev.Logs); ok {
+			matched = append(matched, ev)
+		}
+	}
+
+	if len(matched) != 1 || matched[0].Signature != fixtureSignature {
+		t.Fatalf("matched %v, want only %s", matched, fixtureSignature)
+	}
+
+	detector, _ := matchDetector(c.GetDetectors(), matched[0].Logs)
+
+	data, err := os.ReadFile("testdata/raydium_initialize2_tx.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result helius.GetTransactionResult
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := helius.DecodeTransaction(matched[0].Signature, &result)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instructions := poolInstructions(detector, tx)
+
+	if len(instructions) != 1 {
+		t.Fatalf("found %d initialize2 instructions, want 1", len(instructions))
+	}
+
+	token, ok := e.extractPool(instructions[0].Accounts, detector.Accounts)
+
+	if !ok {
+		t.Fatalf("extractPool failed for %d accounts", len(instructions[0].Accounts))
+	}
+
+	want := map[string]struct {
+		got  *string
+		want string
+	}{
+		"mint":    {&token.ContractAddress, "BMpgvmLkrWfR38sPiCD77B6SobmJcjJMeiuCwz1d5Bin"},
+		"pool":    {token.PoolAddress, "F8gV2sh1GJpbv2h22jhG1ojwBWft2rGNAH6WBwXMZXCN"},
+		"lp mint": {token.LpMint, "813fGVUQBmKTwnhoSpi6yWHMHCCYEuv11Doaye2QMinu"},
+		"quote":   {token.QuoteMint, nativeMint},
+		"creator": {token.Creator, "9gSJxDPBvinus57m63jSZmhkaTxknZ8iHqZAhN3X9Aje"},
+	}
+
+	for name, w := range want {
+		if w.got == nil {
+			t.Errorf("%s is missing, want %s", name, w.want)
+		} else if *w.got != w.want {
+			t.Errorf("%s = %s, want %s", name, *w.got, w.want)
+		}
+	}
+}
+
+// meteoraDetector is the meteora-dlmm entry of the sample config in the README
+var meteoraDetector = config.DetectorConfig{
+	Name:       "meteora-dlmm",
+	ProgramId:  "LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo",
+	LogPattern: "Instruction: InitializeLbPair",
+	DataPrefix: "2d9aedd2dd0fa65c", // anchor discriminator of initialize_lb_pair
+	Accounts: config.AccountRules{
+		Pool:      index(0),
+		BaseMint:  index(2),
+		QuoteMint: index(3),
+		Creator:   index(8),
+	},
+}
+
+func index(i int) *int { return &i }
+
+// The capture holds the subscription confirmation, a swap of the DLMM program and an InitializeLbPair.
+// Token x of the new pair is wrapped SOL, so the new token is token y.
+func TestReplayDetectsMeteoraPool(t *testing.T) {
+	c := testConfig()
+	c.Detectors = append(c.GetDetectors(), meteoraDetector)
+	e := &Engine{config: c}
+
+	events := replay(t, "testdata/meteora_initialize_lb_pair.ndjson")
+
+	if len(events) != 2 {
+		t.Fatalf("replayed %d events, want the 2 log notifications", len(events))
+	}
+
+	if d, ok := matchDetector(c.Detectors, events[0].Logs); ok {
+		t.Errorf("swap matched detector %s", d.Name)
+	}
+
+	detector, ok := matchDetector(c.Detectors, events[1].Logs)
+
+	if !ok || detector.Name != meteoraDetector.Name {
+		t.Fatalf("InitializeLbPair matched %q, want %s", detector.Name, meteoraDetector.Name)
+	}
+
+	tx, err := helius.DecodeTransaction(events[1].Signature, loadTransaction(t, "testdata/meteora_initialize_lb_pair_tx.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instructions := poolInstructions(detector, tx)
+
+	if len(instructions) != 1 {
+		t.Fatalf("found %d InitializeLbPair instructions, want 1", len(instructions))
+	}
+
+	token, ok := e.extractPool(instructions[0].Accounts, detector.Accounts)
+
+	if !ok {
+		t.Fatalf("extractPool failed for %d accounts", len(instructions[0].Accounts))
+	}
+
+	want := map[string]struct {
+		got  *string
+		want string
+	}{
+		"mint":    {&token.ContractAddress, "EwsJ11uT9LzWG5KuTKUmoSUH8Sg4FxUvTEWSNipQUpei"},
+		"pool":    {token.PoolAddress, "HXZ2BMF7vFW8rF6SvosT5jTzFGhYkL5AhyT45em5euuf"},
+		"quote":   {token.QuoteMint, nativeMint},
+		"creator": {token.Creator, "H6UHrh72BaLh48VBf5nQ43suHA4nren4QoSpHzeKrLyK"},
+	}
+
+	for name, w := range want {
+		if w.got == nil {
+			t.Errorf("%s is missing, want %s", name, w.want)
+		} else if *w.got != w.want {
+			t.Errorf("%s = %s, want %s", name, *w.got, w.want)
+		}
+	}
+
+	if token.LpMint != nil {
+		t.Errorf("lp mint = %s, DLMM pairs have none", *token.LpMint)
+	}
+}
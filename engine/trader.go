@@ -5,138 +5,211 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"solana-bot/config"
 	"solana-bot/db"
 	"solana-bot/helius"
 	"solana-bot/jupiter"
+	"solana-bot/mints"
+	"solana-bot/paper"
+	"solana-bot/portfolio"
+	"solana-bot/risk"
+	"solana-bot/rpc"
+	"solana-bot/rugcheck"
+	"solana-bot/sender"
 	"solana-bot/utils"
 	"solana-bot/wallet"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// balances are read from the chain in live mode and from the paper ledger in paper mode
+type balanceSource interface {
+	GetBalance() (int, error)
+	GetTokenHolding(mint string) (wallet.TokenHolding, error)
+}
+
+const (
+	defaultSnapshotInterval = 15 * time.Minute
+	defaultMaxAttempts      = 5
+	defaultRetryBackoff     = 30 * time.Second
+	maxRetryBackoff         = 30 * time.Minute
+)
+
 type Trader struct {
-	c  *config.Config
-	db *db.SqlClient
-	h  *helius.HttpClient
-	j  *jupiter.Client
-	w  *wallet.Client
-
-	cache map[uint64]bool
-	mu    sync.RWMutex
+	c   *config.Config
+	db  *db.SqlClient
+	h   *helius.HttpClient
+	j   *jupiter.Client
+	w   *wallet.Client
+	bal balanceSource
+	p   *portfolio.Tracker
+	rm  *risk.Manager
+	m   *mints.Service
+	rc  *rugcheck.Checker
+	tx  sender.TxSender
+
+	owner  string        // lease owner of this process, see lease.go
+	leases atomic.Uint64 // sequence of the leases acquired by this process
+
+	// held by a buy from its transition to quoting until it passed the risk checks, so every
+	// other buy in flight has been checked and is counted by the risk manager
+	riskMu sync.Mutex
 }
 
 type SwapTokenParams struct {
+	OrderId    uint64
+	LeaseOwner string // the signed swap is only recorded, and sent, while this lease is held
 	InputMint  string
 	OutputMint string
 	Amount     int
+	Fee        config.PriorityFeeConfig
+}
+
+// SwapResult is a swap transaction that was accepted by the rpc node, it still has to land
+type SwapResult struct {
+	TxHash               string
+	LastValidBlockHeight uint64
+	Quote                *jupiter.GetQuoteResponse
+	Simulated            *db.SwapFill // paper mode, the fill that was simulated instead of sending
 }
 
 func (p SwapTokenParams) ToString() string {
 	return fmt.Sprintf("fromToken = %s, toToken = %s, amount =%d", p.InputMint, p.OutputMint, p.Amount)
 }
 
-func (t *Trader) getTokenDecimals(mintAddress string) int {
-	if mintAddress == t.c.Solana.UsdcMint || mintAddress == t.c.Solana.UsdtMint {
-		return 6
-	}
+// A Buy is swapping native sol to the "meme" token address, a SwapFromNativeSol
+func (t *Trader) buyToken(l *orderLease, mintAddress string, amountSol float32, fee config.PriorityFeeConfig) (*SwapResult, error) {
 
-	return 9
-}
+	decimals, err := t.m.Decimals(t.c.Solana.NativeMint)
 
-// A Buy is swapping native sol to the "meme" token address, a SwapFromNativeSol
-func (t *Trader) buyToken(mintAddress string, amountSol float32) (string, error) {
+	if err != nil {
+		return nil, fmt.Errorf("buyToken: %w", err)
+	}
+
+	amountLamport := int(mints.ToAtomic(float64(amountSol), decimals))
 
-	exponential := float32(math.Pow(10, float64(t.getTokenDecimals(mintAddress))))
-	amountLamport := int(amountSol * exponential)
+	bal, err := t.bal.GetBalance()
 
-	bal := t.w.GetBalance()
+	if err != nil {
+		// an rpc failure is not an empty wallet, the order stays pending
+		return nil, fmt.Errorf("buyToken: failed to get balance: %w", err)
+	}
 
 	if bal < amountLamport {
 
 		errMessage := fmt.Sprintf("buyToken: Insufficient Balance, Expected >= %d, Got = %d \n", amountLamport, bal)
 		log.Print(errMessage)
 
-		return "", errors.New(errMessage)
+		return nil, errors.New(errMessage)
 	}
 
 	return t.swap(SwapTokenParams{
+		OrderId:    l.id,
+		LeaseOwner: l.owner,
 		InputMint:  t.c.Solana.NativeMint,
 		OutputMint: mintAddress,
 		Amount:     amountLamport,
+		Fee:        fee,
 	})
 
 }
 
-func (t *Trader) isLocked(id uint64) bool {
-	// obtain a reader's lock
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	_, ok := t.cache[id]
+// checkRisk returns why a token must not be bought, scoring it first when that has not happened yet.
+// An error means the score could not be computed and the order should be retried.
+func (t *Trader) checkRisk(mint string) (string, error) {
+	minScore := t.c.Risk.MinScore
 
-	return ok
-}
+	if minScore <= 0 {
+		return "", nil
+	}
+
+	token := t.db.GetToken(mint)
 
-func (t *Trader) acquireLock(id uint64) bool {
-	if t.isLocked(id) {
-		return false
+	if token == nil {
+		// manual orders may target tokens that were never detected
+		token = &db.TokenEntity{ContractAddress: mint}
 	}
 
-	// obtain a writer's lock
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	t.cache[id] = true
+	score := token.RiskScore
 
-	return true
-}
+	if score == nil {
+		s, _, err := t.rc.Score(*token)
+
+		if err != nil {
+			return "", err
+		}
+
+		score = &s
+	}
+
+	if *score < minScore {
+		return fmt.Sprintf("risk score %.0f is below %.0f", *score, minScore), nil
+	}
 
-func (t *Trader) releaseLock(id uint64) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	delete(t.cache, id)
+	return "", nil
 }
 
-// A Sell is swapping the "meme" token address to native sol, a SwapToNativeSol
-func (t *Trader) sellToken(mintAddress string, rules *string) (string, error) {
+// A Sell is swapping the "meme" token address to native sol, a SwapToNativeSol.
+// Without amount details the whole balance is sold, otherwise a fraction of the balance or an
+// exact token quantity converted with the decimals of the mint.
+func (t *Trader) sellToken(l *orderLease, mintAddress string, amountDetails *string, fee config.PriorityFeeConfig) (*SwapResult, error) {
 
-	bal := t.w.GetTokenBalance(mintAddress)
-	log.Println("TokenBalance", bal)
+	holding, err := t.bal.GetTokenHolding(mintAddress)
+
+	if err != nil {
+		return nil, fmt.Errorf("sellToken: failed to get token balance: %w", err)
+	}
 
-	if rules == nil {
-		// sell everything
-		return t.swap(SwapTokenParams{
-			InputMint:  mintAddress,
-			OutputMint: t.c.Solana.NativeMint,
-			Amount:     bal,
-		})
+	bal := holding.Amount
+	log.Println("TokenBalance", bal)
 
+	if bal == 0 {
+		return nil, fmt.Errorf("sellToken: no %s balance to sell", mintAddress)
 	}
 
-	// TODO - implement way to sell parts of the token
-	// swapRules := utils.Deserialize[db.SwapRules](*rules)
-	// exponential := float32(math.Pow(10, float64(t.getTokenDecimals(mintAddress))))
-	// atomicUnit := int(amountToken * exponential)
 	atomicUnit := bal
 
+	if amountDetails != nil {
+		details := utils.Deserialize[db.AmountDetails](*amountDetails)
+
+		switch {
+		case details.QuantityToken > 0:
+			decimals, err := t.m.Decimals(mintAddress)
+
+			if err != nil {
+				return nil, fmt.Errorf("sellToken: %w", err)
+			}
+
+			atomicUnit = int(mints.ToAtomic(details.QuantityToken, decimals))
+		case details.Fraction > 0 && details.Fraction < 1:
+			atomicUnit = int(float64(bal) * float64(details.Fraction))
+		}
+	}
+
 	if bal < atomicUnit {
 
 		errMessage := fmt.Sprintf("SellToken: Insufficient Balance, Expected >= %d, Got = %d \n", atomicUnit, bal)
 		log.Println(errMessage)
 
-		return "", errors.New(errMessage)
+		return nil, errors.New(errMessage)
 	}
 
 	return t.swap(SwapTokenParams{
+		OrderId:    l.id,
+		LeaseOwner: l.owner,
 		InputMint:  mintAddress,
 		OutputMint: t.c.Solana.NativeMint,
 		Amount:     atomicUnit,
+		Fee:        fee,
 	})
 
 }
 
-func (t *Trader) swap(params SwapTokenParams) (string, error) {
+func (t *Trader) swap(params SwapTokenParams) (*SwapResult, error) {
 
 	quote := t.j.GetQuote(jupiter.GetQuoteParams{
 		InputMint:   params.InputMint,
@@ -147,28 +220,128 @@ func (t *Trader) swap(params SwapTokenParams) (string, error) {
 
 	if quote == nil || len(quote.RoutePlan) < 1 {
 
-		return "", fmt.Errorf("no quote found for swap: %s", params.ToString())
+		return nil, fmt.Errorf("no quote found for swap: %s", params.ToString())
+	}
+
+	if t.c.Trader.Paper.Enabled {
+		return t.simulateSwap(quote, params)
 	}
 
-	swapTx := t.j.BuildSwapTransaction(quote, t.w.PublicKey)
+	// an expired blockhash only needs a freshly built transaction, give it one more attempt
+	for attempt := 1; ; attempt++ {
+		result, err := t.sendSwapTransaction(quote, params)
+
+		if err != nil && (rpc.IsBlockhashNotFound(err) || errors.Is(err, errBlockhashNotFound)) && attempt < 2 {
+			log.Println("swap: Blockhash expired, rebuilding transaction", params.ToString())
+			continue
+		}
+
+		return result, err
+	}
+
+}
+
+func (t *Trader) simulateSwap(quote *jupiter.GetQuoteResponse, params SwapTokenParams) (*SwapResult, error) {
+	fill, err := paper.SimulateFill(quote, params.Amount, t.c.Trader.Paper)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Swap Simulated...", params.ToString())
+
+	return &SwapResult{
+		TxHash:    paper.TxHash(),
+		Quote:     quote,
+		Simulated: &fill,
+	}, nil
+}
+
+func (t *Trader) sendSwapTransaction(quote *jupiter.GetQuoteResponse, params SwapTokenParams) (*SwapResult, error) {
+
+	fee := t.priorityFee(params.Fee, quote)
+	swapTx := t.j.BuildSwapTransaction(quote, t.w.PublicKey, fee)
 
 	if swapTx == nil {
-		return "", fmt.Errorf("failed to BuildSwapTransaction: %s", params.ToString())
+		return nil, fmt.Errorf("failed to BuildSwapTransaction: %s", params.ToString())
+	}
+
+	signedMessage, signature, err := t.w.CreateSignedTxMessage(swapTx.SwapTransaction)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.c.Trader.Simulation.Disabled {
+		err = t.preflightSwap(params.OrderId, signedMessage, quote, swapTx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastValidBlockHeight := uint64(swapTx.LastValidBlockHeight)
+	quotedOutAmount, _ := strconv.ParseUint(quote.OutAmount, 10, 64)
+	computeUnitPrice := uint64(swapTx.PrioritizationType.ComputeBudget.MicroLamports)
+
+	if computeUnitPrice == 0 {
+		computeUnitPrice = fee.ComputeUnitPriceMicroLamports
+	}
+
+	// recorded before sending, a restart finds the signature and checks it on-chain instead of sending again
+	err = t.db.RecordSignedSwap(params.OrderId, params.LeaseOwner, db.SignedSwap{
+		TxHash:               signature,
+		LastValidBlockHeight: lastValidBlockHeight,
+		QuotedOutAmount:      quotedOutAmount,
+		ComputeUnitLimit:     uint64(swapTx.ComputeUnitLimit),
+		ComputeUnitPrice:     computeUnitPrice,
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	signedMessage := t.w.CreateSignedTxMessage(swapTx.SwapTransaction)
-	txHash := t.h.SendTransaction(signedMessage)
+	txHash, err := t.tx.Send(signedMessage)
+
+	if err != nil {
+		var rpcErr *rpc.RPCError
+		var transportErr *rpc.TransportError
+
+		// the request may have reached the node, track the signature until it lands or expires
+		if errors.As(err, &transportErr) {
+			log.Printf("swap: SendTransaction outcome unknown for %s, tracking %s: %s \n", params.ToString(), signature, err)
+
+			return &SwapResult{
+				TxHash:               signature,
+				LastValidBlockHeight: lastValidBlockHeight,
+				Quote:                quote,
+			}, nil
+		}
+
+		if errors.As(err, &rpcErr) && rpcErr.IsPreflightFailure() {
+			log.Printf("swap: Preflight simulation failed for %s \n%s \n", params.ToString(), strings.Join(rpcErr.SimulationLogs(), "\n"))
+		}
 
-	log.Println("Swap Completed... txHash", txHash)
+		return nil, fmt.Errorf("swap: SendTransaction failed for %s: %w", params.ToString(), err)
+	}
 
-	return txHash, nil
+	log.Println("Swap Submitted... txHash", txHash)
+
+	return &SwapResult{
+		TxHash:               txHash,
+		LastValidBlockHeight: lastValidBlockHeight,
+		Quote:                quote,
+	}, nil
 
 }
 
 func (t *Trader) processPendingTrades() {
 
 	for {
-		trades := t.db.GetPendingTrades()
+		t.recoverOrders()
+		t.backfillFills()
+
+		trades := t.db.GetPendingTrades(t.c.Trader.Paper.Enabled)
 
 		if len(trades) > 0 {
 			fmt.Printf("ProcessPendingTrades: Found %v pending trades \n", len(trades))
@@ -183,47 +356,177 @@ func (t *Trader) processPendingTrades() {
 
 func (t *Trader) executeTrade(tr db.SwapTradeEntity) {
 
-	// acquire the lock
-	lock := t.acquireLock(tr.Id)
+	l, ok := t.acquireLease(tr.Id)
 
-	if !lock {
-		fmt.Printf("Id = %d is already locked for processing \n", tr.Id)
+	if !ok {
+		fmt.Printf("Id = %d is already leased for processing \n", tr.Id)
 
 		return
 	}
 
-	var txHash string
+	defer t.releaseLease(l)
 
-	// for buy orders we set the amount
-	if tr.AmountDetails != nil {
+	attempt := tr.Attempts + 1
+
+	if tr.IsBuy() {
+		t.riskMu.Lock()
+	}
+
+	// the signature of a previous attempt is cleared, that attempt did not land
+	err := t.db.TransitionSwapOrder(tr.Id, db.Transition{
+		To:     db.OrderStatusQuoting,
+		Reason: fmt.Sprintf("attempt %d", attempt),
+		Set:    map[string]any{"attempts": attempt, "nextRetryAt": nil, "txHash": nil, "lastValidBlockHeight": nil},
+	})
+
+	if err != nil {
+		if tr.IsBuy() {
+			t.riskMu.Unlock()
+		}
+
+		log.Println("executeTrade:", err)
+
+		return
+	}
+
+	var result *SwapResult
+
+	// for buy orders we set the amount of sol
+	if tr.IsBuy() {
 
 		amtDetails := utils.Deserialize[db.AmountDetails](*tr.AmountDetails)
 
-		hash, err := t.buyToken(tr.ToToken, amtDetails.QuantitySol)
+		var reason string
+		reason, err = t.checkRisk(tr.ToToken)
+
+		if err == nil && len(reason) == 0 {
+			reason, err = t.rm.CheckBuy(tr.Id, tr.ToToken, float64(amtDetails.QuantitySol))
+		}
 
+		// the order leaves quoting before the next buy is checked, it must not count as in flight
 		if err != nil {
-			log.Println("executeTrade: buy failed", err)
+			t.retrySwapOrder(tr.Id, attempt, fmt.Errorf("risk check failed: %w", err))
+			t.riskMu.Unlock()
+
+			return
+		}
+
+		if len(reason) > 0 {
+			log.Printf("executeTrade: Order %d rejected, %s \n", tr.Id, reason)
+
+			err = t.db.CancelSwapOrder(tr.Id, reason)
+
+			if err != nil {
+				log.Println("executeTrade:", err)
+			}
+
+			t.riskMu.Unlock()
+
+			return
+		}
+
+		t.riskMu.Unlock()
+
+		if l.Lost() {
+			log.Printf("executeTrade: Order %d lease lost, stopping \n", tr.Id)
+
+			return
 		}
 
-		txHash = hash
+		result, err = t.buyToken(l, tr.ToToken, amtDetails.QuantitySol, t.feeStrategy(tr))
 
 	} else {
 
-		hash, err := t.sellToken(tr.FromToken, tr.Rules)
+		result, err = t.sellToken(l, tr.FromToken, tr.AmountDetails, t.feeStrategy(tr))
+
+	}
+
+	// another process took the order over, it decides what happens to it
+	if errors.Is(err, db.ErrLeaseLost) || l.Lost() {
+		log.Printf("executeTrade: Order %d lease lost, stopping \n", tr.Id)
+
+		return
+	}
+
+	if err != nil {
+		t.retrySwapOrder(tr.Id, attempt, err)
+
+		return
+	}
+
+	if result.Simulated != nil {
+		err = t.db.RecordSimulatedSwap(tr.Id, result.TxHash, result.Quote.InputMint, result.Quote.OutputMint, t.c.Solana.NativeMint, *result.Simulated)
 
 		if err != nil {
-			log.Println("executeTrade: sell failed", err)
+			t.retrySwapOrder(tr.Id, attempt, err)
 		}
 
-		txHash = hash
+		return
+	}
+
+	quotedOutAmount, _ := strconv.ParseUint(result.Quote.OutAmount, 10, 64)
+
+	err = t.db.SubmitSwapOrder(tr.Id, result.TxHash, result.LastValidBlockHeight, quotedOutAmount)
+
+	if err != nil {
+		// the transaction is out, keep tracking it even though the order could not record it
+		log.Printf("executeTrade: Order %d sent %s but failed to record it: %s \n", tr.Id, result.TxHash, err)
+	}
+
+	t.confirmSwapOrder(submittedSwap{
+		Lease:                l,
+		OrderId:              tr.Id,
+		Attempt:              attempt,
+		TxHash:               result.TxHash,
+		LastValidBlockHeight: result.LastValidBlockHeight,
+		InputMint:            result.Quote.InputMint,
+		OutputMint:           result.Quote.OutputMint,
+		QuotedOutAmount:      quotedOutAmount,
+	})
+
+}
+
+// retrySwapOrder puts an order whose attempt failed back to pending with an exponential backoff,
+// or fails it for good once it used all of its attempts. The transaction of the failed attempt
+// did not land, its signature is cleared so a pending order never looks sent.
+func (t *Trader) retrySwapOrder(id uint64, attempt int, cause error) {
+	maxAttempts := defaultMaxAttempts
+
+	if t.c.Trader.MaxAttempts > 0 {
+		maxAttempts = t.c.Trader.MaxAttempts
+	}
+
+	backoff := defaultRetryBackoff
+
+	if t.c.Trader.RetryBackoffSeconds > 0 {
+		backoff = time.Duration(t.c.Trader.RetryBackoffSeconds) * time.Second
+	}
+
+	lastError := cause.Error()
+	log.Printf("retrySwapOrder: Order %d attempt %d/%d failed: %s \n", id, attempt, maxAttempts, lastError)
 
+	tr := db.Transition{
+		To:     db.OrderStatusFailed,
+		Reason: lastError,
+		Set:    map[string]any{"lastError": lastError},
 	}
 
-	t.db.UpdateSwapOrder(txHash, tr.Id)
+	if attempt < maxAttempts {
+		for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+			backoff *= 2
+		}
+
+		tr.To = db.OrderStatusPending
+		tr.Set["nextRetryAt"] = time.Now().Add(min(backoff, maxRetryBackoff)).UnixMilli()
+		tr.Set["txHash"] = nil
+		tr.Set["lastValidBlockHeight"] = nil
+	}
 
-	// release lock after processing
-	t.releaseLock(tr.Id)
+	err := t.db.TransitionSwapOrder(id, tr)
 
+	if err != nil {
+		log.Println("retrySwapOrder:", err)
+	}
 }
 
 func (t *Trader) loadTrades() {
@@ -246,17 +549,58 @@ func (t *Trader) loadTrades() {
 
 func (t *Trader) Start() {
 	// t.loadTrades()
-	log.Println("balance", t.w.GetBalance())
+	bal, err := t.bal.GetBalance()
+
+	if err != nil {
+		log.Println("Trader: Failed to get balance", err)
+	} else {
+		log.Println("balance", bal)
+	}
+
+	go t.monitorPositions()
+	go t.runEntryStrategies()
+	go t.recordPortfolio()
 	t.processPendingTrades()
 }
 
-func NewTrader(w *wallet.Client, j *jupiter.Client, h *helius.HttpClient, c *config.Config, db *db.SqlClient) *Trader {
+// recordPortfolio writes a portfolio snapshot periodically, the equity curve of the bot
+func (t *Trader) recordPortfolio() {
+	interval := defaultSnapshotInterval
+
+	if t.c.Trader.SnapshotIntervalMinutes > 0 {
+		interval = time.Duration(t.c.Trader.SnapshotIntervalMinutes) * time.Minute
+	}
+
+	for {
+		t.p.Record()
+
+		time.Sleep(interval)
+	}
+}
+
+func NewTrader(w *wallet.Client, j *jupiter.Client, h *helius.HttpClient, m *mints.Service, rc *rugcheck.Checker, c *config.Config, db *db.SqlClient) *Trader {
+	var bal balanceSource = w
+
+	if c.Trader.Paper.Enabled {
+		log.Println("NewTrader: Paper mode, swaps are simulated")
+
+		bal = paper.NewWallet(c.Trader.Paper, db, m, c.Solana.NativeMint)
+	}
+
+	p := portfolio.New(db, m, bal, c.Solana.NativeMint, c.Trader.Paper.Enabled)
+
 	return &Trader{
 		w:     w,
+		bal:   bal,
+		p:     p,
+		rm:    risk.New(&c.RiskManager, db, p, bal, c.Trader.Paper.Enabled),
+		m:     m,
+		rc:    rc,
+		tx:    sender.New(&c.Trader, h, w),
 		j:     j,
 		h:     h,
 		c:     c,
 		db:    db,
-		cache: make(map[uint64]bool),
+		owner: leaseOwner(),
 	}
 }
@@ -0,0 +1,124 @@
+package helius
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultCaptureFileBytes = 64 * 1024 * 1024
+
+// CaptureRecord is one line of a capture file
+type CaptureRecord struct {
+	ReceivedAt int64           `json:"receivedAt"` // unix milliseconds
+	Frame      json.RawMessage `json:"frame"`
+}
+
+// FrameRecorder appends raw websocket frames to newline-delimited JSON files in dir,
+// starting a new file whenever the current one grows past maxBytes
+type FrameRecorder struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	file    *os.File
+	written int64
+	closed  bool
+}
+
+func (r *FrameRecorder) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	name := filepath.Join(r.dir, fmt.Sprintf("capture-%s.ndjson", time.Now().Format("20060102-150405.000")))
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("FrameRecorder: Capturing frames to", name)
+
+	r.file = file
+	r.written = 0
+
+	return nil
+}
+
+func (r *FrameRecorder) Record(frame []byte, receivedAt time.Time) {
+
+	if !json.Valid(frame) {
+		log.Println("FrameRecorder: Skipping frame that is not valid JSON")
+
+		return
+	}
+
+	line, err := json.Marshal(CaptureRecord{
+		ReceivedAt: receivedAt.UnixMilli(),
+		Frame:      frame,
+	})
+
+	if err != nil {
+		log.Println("FrameRecorder:", err)
+
+		return
+	}
+
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	if r.file == nil || r.written >= r.maxBytes {
+		err = r.rotate()
+
+		if err != nil {
+			log.Println("FrameRecorder: Failed to open capture file", err)
+
+			return
+		}
+	}
+
+	n, err := r.file.Write(line)
+	r.written += int64(n)
+
+	if err != nil {
+		log.Println("FrameRecorder:", err)
+	}
+}
+
+func (r *FrameRecorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+func NewFrameRecorder(dir string, maxBytes int64) (*FrameRecorder, error) {
+	err := os.MkdirAll(dir, 0755)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if maxBytes <= 0 {
+		maxBytes = defaultCaptureFileBytes
+	}
+
+	return &FrameRecorder{dir: dir, maxBytes: maxBytes}, nil
+}
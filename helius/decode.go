@@ -0,0 +1,140 @@
+package helius
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+// decode returns the transaction of a base64 encoded getTransaction result and its account keys.
+// Account indexes refer to the static keys followed by the writable and readonly lookup table addresses.
+func decode(result *GetTransactionResult) (*solana.Transaction, []string, error) {
+	// base64 encoded transactions come as [data, encoding]
+	var encoded [2]string
+
+	err := json.Unmarshal(result.Transaction, &encoded)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected transaction encoding %w", err)
+	}
+
+	tx, err := solana.TransactionFromBase64(encoded[0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+
+	for _, key := range tx.Message.AccountKeys {
+		keys = append(keys, key.String())
+	}
+
+	keys = append(keys, result.Meta.LoadedAddresses.Writable...)
+	keys = append(keys, result.Meta.LoadedAddresses.Readonly...)
+
+	return tx, keys, nil
+}
+
+// TransactionKeys returns the resolved account keys and the number of signatures of a getTransaction result
+func TransactionKeys(result *GetTransactionResult) ([]string, int, error) {
+	tx, keys, err := decode(result)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("TransactionKeys: %w", err)
+	}
+
+	return keys, len(tx.Signatures), nil
+}
+
+// DecodeTransaction converts a base64 encoded getTransaction result into the ParsedTx shape
+// returned by the enhanced transactions API
+func DecodeTransaction(signature string, result *GetTransactionResult) (ParsedTx, error) {
+	parsed := ParsedTx{Signature: signature}
+
+	tx, keys, err := decode(result)
+
+	if err != nil {
+		return parsed, fmt.Errorf("DecodeTransaction: %w", err)
+	}
+
+	resolve := func(programIdIndex uint16, accounts []uint16, data string) (Instruction, error) {
+		inc := Instruction{Data: data}
+
+		if int(programIdIndex) >= len(keys) {
+			return inc, fmt.Errorf("program id index %d out of range", programIdIndex)
+		}
+
+		inc.ProgramId = keys[programIdIndex]
+
+		for _, a := range accounts {
+			if int(a) >= len(keys) {
+				return inc, fmt.Errorf("account index %d out of range", a)
+			}
+
+			inc.Accounts = append(inc.Accounts, keys[a])
+		}
+
+		return inc, nil
+	}
+
+	for _, ci := range tx.Message.Instructions {
+		inc, err := resolve(ci.ProgramIDIndex, ci.Accounts, base58.Encode(ci.Data))
+
+		if err != nil {
+			return parsed, fmt.Errorf("DecodeTransaction: %s %w", signature, err)
+		}
+
+		parsed.Instructions = append(parsed.Instructions, TxInstruction{Instruction: inc})
+	}
+
+	for _, inner := range result.Meta.InnerInstructions {
+		if inner.Index >= len(parsed.Instructions) {
+			continue
+		}
+
+		for _, ci := range inner.Instructions {
+			inc, err := resolve(ci.ProgramIdIndex, ci.Accounts, ci.Data)
+
+			if err != nil {
+				return parsed, fmt.Errorf("DecodeTransaction: %s %w", signature, err)
+			}
+
+			parsed.Instructions[inner.Index].InnerInstructions = append(parsed.Instructions[inner.Index].InnerInstructions, inc)
+		}
+	}
+
+	return parsed, nil
+}
+
+// GetParsedTxsFromRPC is the JSON-RPC alternative to GetParsedTxs, it works against any Solana RPC provider
+func (h *HttpClient) GetParsedTxsFromRPC(signatures []string) ([]ParsedTx, error) {
+	var parsedTxs []ParsedTx
+
+	for _, signature := range signatures {
+		result, err := h.GetTransaction(signature, "confirmed")
+
+		if err != nil {
+			return parsedTxs, err
+		}
+
+		if result == nil {
+			log.Printf("GetParsedTxsFromRPC: Transaction %s not found \n", signature)
+			continue
+		}
+
+		parsed, err := DecodeTransaction(signature, result)
+
+		if err != nil {
+			log.Println("GetParsedTxsFromRPC:", err)
+			continue
+		}
+
+		parsedTxs = append(parsedTxs, parsed)
+	}
+
+	return parsedTxs, nil
+}
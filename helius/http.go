@@ -2,15 +2,17 @@ package helius
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"solana-bot/config"
+	"solana-bot/rpc"
 )
 
 type HttpClient struct {
 	config *config.HeliusConfig
+	rpc    *rpc.Client
 }
 
 func (h *HttpClient) GetParsedTxs(signatures []string) ([]ParsedTx, error) {
@@ -40,65 +42,42 @@ func (h *HttpClient) GetParsedTxs(signatures []string) ([]ParsedTx, error) {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return nil, fmt.Errorf("GetParsedTxs: Request failed: %s", err)
+		return nil, &rpc.TransportError{Method: "GetParsedTxs", Url: h.config.BaseApiUrl, Err: err}
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		log.Println()
-
-		return nil, fmt.Errorf("GetParsedTxs: Failed to retrieve tx data %d", resp.StatusCode)
+		return nil, &rpc.HTTPStatusError{Method: "GetParsedTxs", StatusCode: resp.StatusCode}
 	}
 
 	var parsedTxs []ParsedTx
 
-	json.NewDecoder(resp.Body).Decode(&parsedTxs)
+	err = json.NewDecoder(resp.Body).Decode(&parsedTxs)
+
+	if err != nil {
+		return nil, &rpc.DecodeError{Method: "GetParsedTxs", Err: err}
+	}
 
 	return parsedTxs, nil
 
 }
 
 // returns the sol balance in lamports
-func (h *HttpClient) GetBalance(address string) int {
-	url := fmt.Sprintf("%s?api-key=%s", h.config.RpcUrl, h.config.ApiKey)
-
-	reqBody, err := json.Marshal(GetBalanceRequestBody{
-		BaseRPCBody: BaseRPCBody{
-			ID:      1,
-			JsonRPC: "2.0",
-			Method:  "getBalance",
-		},
-		Params: []string{address},
-	})
-
-	if err != nil {
-		log.Println("GetBalance: Failed to marshal body", err)
-
-		return 0
-	}
+func (h *HttpClient) GetBalance(address string) (int, error) {
+	var result GetBalanceResult
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	err := h.call("getBalance", []interface{}{address}, &result)
 
 	if err != nil {
-		log.Println("GetBalance: Failed to Get url", err)
-
-		return 0
+		return 0, err
 	}
 
-	defer resp.Body.Close()
-
-	var responseBody GetBalanceResponseBody
-
-	json.NewDecoder(resp.Body).Decode(&responseBody)
-
-	return responseBody.Result.Value
+	return result.Value, nil
 
 }
 
-func (h *HttpClient) GetTokenAccountsByOwner(address, mint string) *GetTokenAccountsByOwnerResponseBody {
-	url := fmt.Sprintf("%s?api-key=%s", h.config.RpcUrl, h.config.ApiKey)
-
+func (h *HttpClient) GetTokenAccountsByOwner(address, mint string) (*GetTokenAccountsByOwnerResult, error) {
 	var params []interface{}
 
 	params = append(params, address)
@@ -112,77 +91,172 @@ func (h *HttpClient) GetTokenAccountsByOwner(address, mint string) *GetTokenAcco
 		Encoding: "jsonParsed",
 	})
 
-	reqBody, err := json.Marshal(GetTokenAccountsByOwnerRequestBody{
-		BaseRPCBody: BaseRPCBody{
-			ID:      1,
-			JsonRPC: "2.0",
-			Method:  "getTokenAccountsByOwner",
-		},
-		Params: params,
-	})
+	var result GetTokenAccountsByOwnerResult
 
-	if err != nil {
-		log.Println("GetTokenAccountsByOwner: Failed to marshal body", err)
+	err := h.call("getTokenAccountsByOwner", params, &result)
 
-		return nil
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	return &result, nil
+}
+
+// returns the signature of the transaction, a failed preflight simulation comes back as an *rpc.RPCError
+// carrying the simulation logs
+func (h *HttpClient) SendTransaction(txMsg string) (string, error) {
+	var result string
+
+	err := h.call("sendTransaction", []interface{}{txMsg}, &result)
 
 	if err != nil {
-		log.Println("GetTokenAccountsByOwner: Failed to Get url", err)
+		return "", err
+	}
+
+	return result, nil
+
+}
+
+// returns one status per signature, nil when the signature is unknown to the node
+func (h *HttpClient) GetSignatureStatuses(signatures []string) ([]*SignatureStatus, error) {
+	var result GetSignatureStatusesResult
+
+	err := h.call("getSignatureStatuses", []interface{}{signatures, map[string]bool{
+		"searchTransactionHistory": true,
+	}}, &result)
 
-		return nil
+	if err != nil {
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	return result.Value, nil
+}
 
-	var result GetTokenAccountsByOwnerResponseBody
+func (h *HttpClient) GetBlockHeight(commitment string) (uint64, error) {
+	var result uint64
 
-	json.NewDecoder(resp.Body).Decode(&result)
+	err := h.call("getBlockHeight", []interface{}{map[string]string{"commitment": commitment}}, &result)
 
-	return &result
+	return result, err
 }
 
-func (h *HttpClient) SendTransaction(txMsg string) string {
-	url := fmt.Sprintf("%s?api-key=%s", h.config.RpcUrl, h.config.ApiKey)
+// call sends a JSON-RPC request through the rpc client, which fails over between endpoints
+func (h *HttpClient) call(method string, params []interface{}, result interface{}) error {
+	return h.rpc.Call(method, params, result)
+}
 
-	reqBody, err := json.Marshal(struct {
-		BaseRPCBody
-		Params []string `json:"params"`
-	}{
-		BaseRPCBody: BaseRPCBody{
-			ID:      1,
-			JsonRPC: "2.0",
-			Method:  "sendTransaction",
-		},
-		Params: []string{txMsg},
-	})
+// returns signatures for transactions involving the address, newest first
+func (h *HttpClient) GetSignaturesForAddress(address string, opts GetSignaturesForAddressOptions) ([]SignatureInfo, error) {
+	var result []SignatureInfo
 
-	if err != nil {
-		log.Println("SendTransaction: Failed to marshal body", err)
+	err := h.call("getSignaturesForAddress", []interface{}{address, opts}, &result)
+
+	return result, err
+}
+
+// returns nil when the transaction is not (yet) available at the given commitment
+func (h *HttpClient) GetTransaction(signature string, commitment string) (*GetTransactionResult, error) {
+	var result *GetTransactionResult
+
+	err := h.call("getTransaction", []interface{}{signature, map[string]interface{}{
+		"encoding":                       "base64",
+		"commitment":                     commitment,
+		"maxSupportedTransactionVersion": 0,
+	}}, &result)
+
+	return result, err
+}
 
-		return ""
+// returns the raw account data, nil when the account does not exist
+func (h *HttpClient) GetAccountInfo(address string) (*AccountInfo, []byte, error) {
+	var result GetAccountInfoResult
+
+	err := h.call("getAccountInfo", []interface{}{address, map[string]string{
+		"encoding":   "base64",
+		"commitment": "confirmed",
+	}}, &result)
+
+	if err != nil || result.Value == nil {
+		return nil, nil, err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	if len(result.Value.Data) == 0 {
+		return nil, nil, &rpc.DecodeError{Method: "getAccountInfo", Err: fmt.Errorf("account %s has no data", address)}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(result.Value.Data[0])
+
+	if err != nil {
+		return nil, nil, &rpc.DecodeError{Method: "getAccountInfo", Err: err}
+	}
+
+	return result.Value, data, nil
+}
+
+// returns the 20 largest token accounts of a mint
+func (h *HttpClient) GetTokenLargestAccounts(mint string) ([]TokenAccountBalance, error) {
+	var result GetTokenLargestAccountsResult
+
+	err := h.call("getTokenLargestAccounts", []interface{}{mint, map[string]string{"commitment": "confirmed"}}, &result)
+
+	return result.Value, err
+}
+
+// returns the owner of every token account, accounts that do not exist are left out
+func (h *HttpClient) GetTokenAccountOwners(addresses []string) (map[string]string, error) {
+	var result GetMultipleTokenAccountsResult
+
+	err := h.call("getMultipleAccounts", []interface{}{addresses, map[string]string{
+		"encoding":   "jsonParsed",
+		"commitment": "confirmed",
+	}}, &result)
 
 	if err != nil {
-		log.Println("SendTransaction: Failed to Post to url", err)
+		return nil, err
+	}
+
+	owners := make(map[string]string)
 
-		return ""
+	for i, account := range result.Value {
+		if account != nil && i < len(addresses) {
+			owners[addresses[i]] = account.Data.Parsed.Info.Owner
+		}
 	}
 
-	defer resp.Body.Close()
+	return owners, nil
+}
 
-	var result SendTransactionResponse
+func NewHttpClient(c *config.HeliusConfig, rpc *rpc.Client) *HttpClient {
+	return &HttpClient{config: c, rpc: rpc}
+}
 
-	json.NewDecoder(resp.Body).Decode(&result)
+// returns the prioritization fees (micro-lamports per compute unit) paid in recent slots by
+// transactions that write-lock all of the accounts, at most 128 accounts
+func (h *HttpClient) GetRecentPrioritizationFees(accounts []string) ([]PrioritizationFee, error) {
+	var result []PrioritizationFee
 
-	return result.Result
+	err := h.call("getRecentPrioritizationFees", []interface{}{accounts}, &result)
 
+	return result, err
 }
 
-func NewHttpClient(c *config.HeliusConfig) *HttpClient {
-	return &HttpClient{config: c}
+// simulates a signed, base58 encoded transaction and returns the state of the given accounts after it
+func (h *HttpClient) SimulateTransaction(txMsg string, addresses []string) (*SimulateTransactionResult, error) {
+	var result SimulateTransactionResult
+
+	err := h.call("simulateTransaction", []interface{}{txMsg, map[string]interface{}{
+		"encoding":   "base58",
+		"commitment": "processed",
+		"sigVerify":  true,
+		"accounts": map[string]interface{}{
+			"encoding":  "base64",
+			"addresses": addresses,
+		},
+	}}, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
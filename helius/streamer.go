@@ -1,101 +1,397 @@
 package helius
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"math/rand"
 	"solana-bot/config"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-func createConnection(c *config.HeliusConfig) *websocket.Conn {
-	wsUrl := fmt.Sprintf("%s?api-key=%s", c.WebSocketUrl, c.ApiKey)
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultPingInterval   = 20 * time.Second
+
+	// a connection that stays up this long without a notification still counts as a success
+	stableConnection = time.Minute
+)
+
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateSubscribed
+	StateDegraded
+	StateStopped
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateSubscribed:
+		return "subscribed"
+	case StateDegraded:
+		return "degraded"
+	case StateStopped:
+		return "stopped"
+	}
+
+	return "unknown"
+}
+
+var errStreamerClosed = errors.New("streamer closed")
+
+type Streamer struct {
+	conn    *websocket.Conn
+	connMu  sync.Mutex
+	msgCh   chan []byte
+	stateCh chan ConnectionState
+	done    chan struct{}
+	once    sync.Once
+	config  *config.HeliusConfig
+
+	// program ids to subscribe to, logsSubscribe only accepts one mention per subscription
+	mentions []string
+
+	// optional, records every raw frame for later replay
+	recorder *FrameRecorder
+}
+
+func (s *Streamer) dial() (*websocket.Conn, error) {
+	wsUrl := fmt.Sprintf("%s?api-key=%s", s.config.WebSocketUrl, s.config.ApiKey)
 
 	conn, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
 
 	if err != nil {
-		log.Fatal("Failed to establish websocket connect", err)
+		return nil, err
 	}
 
 	log.Println("Connection established")
 
-	return conn
-}
-
-type Streamer struct {
-	conn   *websocket.Conn
-	msgCh  chan []byte
-	config *config.HeliusConfig
+	return conn, nil
 }
 
 func (s *Streamer) Close() {
-	s.conn.Close()
-}
+	s.once.Do(func() {
+		close(s.done)
+	})
 
-func (s *Streamer) Reconnect() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
 
-	log.Println("Reconnecting...")
+	if s.conn != nil {
+		s.conn.Close()
+	}
 
-	// create new connection
-	s.conn = createConnection(s.config)
-	// subscribe to logs again
-	s.SubscribeToLogs()
-	// start reading messages into the channel
-	s.ReadMessages()
+	if s.recorder != nil {
+		s.recorder.Close()
+	}
+}
 
+func (s *Streamer) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *Streamer) GetMessageChannel() chan []byte {
 	return s.msgCh
 }
 
-func (s *Streamer) ReadMessages() {
-	for {
+// States reports every connection state transition, the channel is closed once the streamer stops
+func (s *Streamer) States() <-chan ConnectionState {
+	return s.stateCh
+}
 
-		_, message, err := s.conn.ReadMessage()
+func (s *Streamer) setState(state ConnectionState) {
+	log.Println("Streamer: state =", state)
 
-		if err != nil {
-			log.Println("ReadMessages:", err)
+	// never block the connection loop on a slow consumer
+	select {
+	case s.stateCh <- state:
+	default:
+	}
+}
+
+func (s *Streamer) backoff(attempt int) time.Duration {
+	initial := defaultInitialBackoff
+	maxBackoff := defaultMaxBackoff
 
-			if strings.Contains(err.Error(), "connection reset") {
-				log.Println("Connection reset: Handle reconnection")
-			} else {
-				s.Close()
+	if s.config.Reconnect.InitialBackoffMs > 0 {
+		initial = time.Duration(s.config.Reconnect.InitialBackoffMs) * time.Millisecond
+	}
+
+	if s.config.Reconnect.MaxBackoffMs > 0 {
+		maxBackoff = time.Duration(s.config.Reconnect.MaxBackoffMs) * time.Millisecond
+	}
+
+	wait := initial
+
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	wait = min(wait, maxBackoff)
+
+	// equal jitter: sleep somewhere between half and the full backoff
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func (s *Streamer) pingInterval() time.Duration {
+	if s.config.Reconnect.PingIntervalSeconds > 0 {
+		return time.Duration(s.config.Reconnect.PingIntervalSeconds) * time.Second
+	}
+
+	return defaultPingInterval
+}
+
+// sleep waits for d, returns false if the streamer was closed in the meantime
+func (s *Streamer) sleep(d time.Duration) bool {
+	select {
+	case <-s.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Run supervises the websocket connection: it dials, subscribes, reads messages into the
+// message channel and reconnects with exponential backoff whenever the connection drops.
+// It returns once the streamer is closed or the retry budget is exhausted.
+func (s *Streamer) Run() {
+	defer close(s.msgCh)
+	defer close(s.stateCh)
+
+	failures := 0
+
+	for !s.isClosed() {
+
+		s.setState(StateConnecting)
+
+		err := s.connect()
+
+		if err == nil {
+			var healthy bool
+
+			// a connection dropped right after the subscribe keeps counting as a failure
+			healthy, err = s.readMessages()
+
+			if healthy {
+				failures = 0
 			}
+		}
+
+		if s.isClosed() {
+			break
+		}
 
-			s.Reconnect()
+		log.Println("Streamer:", err)
 
-			return
+		failures++
+
+		if s.config.Reconnect.MaxRetries > 0 && failures > s.config.Reconnect.MaxRetries {
+			log.Printf("Streamer: Giving up after %d consecutive failed attempts \n", failures-1)
+
+			break
 		}
 
-		s.msgCh <- message
+		s.setState(StateDegraded)
+
+		wait := s.backoff(failures)
+		log.Printf("Streamer: Reconnecting in %s (attempt %d) \n", wait, failures)
 
+		if !s.sleep(wait) {
+			break
+		}
 	}
-}
 
-func (s *Streamer) SubscribeToLogs() {
+	s.setState(StateStopped)
+}
 
-	contents, err := os.ReadFile("./logSubscribe.json")
+func (s *Streamer) connect() error {
+	conn, err := s.dial()
 
 	if err != nil {
-		log.Fatal("failed to open logSubscribe file")
+		return fmt.Errorf("failed to establish websocket connection: %w", err)
+	}
+
+	s.connMu.Lock()
+	if s.isClosed() {
+		s.connMu.Unlock()
+		conn.Close()
+
+		return errStreamerClosed
 	}
+	s.conn = conn
+	s.connMu.Unlock()
 
-	err = s.conn.WriteMessage(websocket.TextMessage, contents)
+	// subscribe to logs again on every new connection
+	err = s.SubscribeToLogs()
 
 	if err != nil {
-		log.Println("write:", err)
-		return
+		conn.Close()
+
+		return fmt.Errorf("failed to subscribe to logs: %w", err)
 	}
 
+	return nil
 }
 
-func NewStreamer(c *config.HeliusConfig) *Streamer {
-	return &Streamer{
-		conn:   createConnection(c),
-		msgCh:  make(chan []byte, 1024),
-		config: c,
+// subscriptionReply is the part of a frame needed to tell a subscribe reply from a notification
+type subscriptionReply struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// readMessages forwards frames to the message channel until the connection drops. The state is
+// subscribed once every logsSubscribe was confirmed, the connection is reported healthy once a
+// notification arrived or it stayed up for stableConnection.
+func (s *Streamer) readMessages() (bool, error) {
+	conn := s.conn
+	defer conn.Close()
+
+	connectedAt := time.Now()
+	confirmed := 0
+	notified := false
+
+	healthy := func() bool {
+		return notified || time.Since(connectedAt) >= stableConnection
 	}
+
+	pingInterval := s.pingInterval()
+	pongWait := 2 * pingInterval
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopPing:
+				return
+			case <-ticker.C:
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingInterval))
+
+				if err != nil {
+					log.Println("Streamer: ping failed", err)
+
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+
+		if err != nil {
+			return healthy(), fmt.Errorf("ReadMessages: %w", err)
+		}
+
+		receivedAt := time.Now()
+
+		// any frame proves the connection is alive
+		conn.SetReadDeadline(receivedAt.Add(pongWait))
+
+		if s.recorder != nil {
+			s.recorder.Record(message, receivedAt)
+		}
+
+		var reply subscriptionReply
+
+		if json.Unmarshal(message, &reply) == nil {
+			switch {
+			case len(reply.Method) > 0:
+				notified = true
+			case len(reply.Error) > 0:
+				return healthy(), fmt.Errorf("ReadMessages: subscription %d rejected: %s", reply.ID, reply.Error)
+			case len(reply.Result) > 0:
+				confirmed++
+
+				if confirmed == len(s.mentions) {
+					s.setState(StateSubscribed)
+				}
+			}
+		}
+
+		select {
+		case <-s.done:
+			return healthy(), errStreamerClosed
+		case s.msgCh <- message:
+		}
+	}
+}
+
+func (s *Streamer) SubscribeToLogs() error {
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	for i, mention := range s.mentions {
+		contents, err := json.Marshal(RPCRequestBody{
+			BaseRPCBody: BaseRPCBody{
+				ID:      i + 1,
+				JsonRPC: "2.0",
+				Method:  "logsSubscribe",
+			},
+			Params: []interface{}{
+				map[string][]string{"mentions": {mention}},
+				map[string]string{"commitment": "finalized"},
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = s.conn.WriteMessage(websocket.TextMessage, contents)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewStreamer(c *config.HeliusConfig, mentions []string) *Streamer {
+	s := &Streamer{
+		msgCh:    make(chan []byte, 1024),
+		stateCh:  make(chan ConnectionState, 16),
+		done:     make(chan struct{}),
+		config:   c,
+		mentions: mentions,
+	}
+
+	if len(c.Capture.Dir) > 0 {
+		recorder, err := NewFrameRecorder(c.Capture.Dir, int64(c.Capture.MaxFileMB)*1024*1024)
+
+		if err != nil {
+			log.Println("NewStreamer: Capture disabled, failed to create recorder", err)
+		} else {
+			s.recorder = recorder
+		}
+	}
+
+	return s
 }
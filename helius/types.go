@@ -1,5 +1,7 @@
 package helius
 
+import "encoding/json"
+
 type GetParsedTxReqBody struct {
 	Transactions []string `json:"transactions"`
 }
@@ -10,18 +12,20 @@ type BaseRPCBody struct {
 	Method  string `json:"method"`
 }
 
+type Instruction struct {
+	Data      string   `json:"data"` // base58 encoded
+	ProgramId string   `json:"programId"`
+	Accounts  []string `json:"accounts"`
+}
+
+type TxInstruction struct {
+	Instruction
+	InnerInstructions []Instruction `json:"innerInstructions"`
+}
+
 type ParsedTx struct {
-	Signature    string `json:"signature"`
-	Instructions []struct {
-		Data              string   `json:"data"`
-		ProgramId         string   `json:"programId"`
-		Accounts          []string `json:"accounts"`
-		InnerInstructions []struct {
-			Data      string   `json:"data"`
-			ProgramId string   `json:"programId"`
-			Accounts  []string `json:"accounts"`
-		} `json:"innerInstructions"`
-	} `json:"instructions"`
+	Signature    string          `json:"signature"`
+	Instructions []TxInstruction `json:"instructions"`
 }
 
 type LogSubscribeMessage struct {
@@ -36,7 +40,7 @@ type messageParams struct {
 
 type result struct {
 	Context struct {
-		Slot int32 `json:"slot"`
+		Slot uint64 `json:"slot"`
 	} `json:"context"`
 
 	Value struct {
@@ -45,70 +49,187 @@ type result struct {
 	} `json:"value"`
 }
 
-type GetBalanceRequestBody struct {
-	BaseRPCBody
-	Params []string `json:"params"`
+type GetBalanceResult struct {
+	Context struct {
+		APIVersion string `json:"apiVersion"`
+		Slot       int    `json:"slot"`
+	} `json:"context"`
+	Value int `json:"value"`
 }
 
-type GetTokenAccountsByOwnerRequestBody struct {
+type GetTokenAccountsByOwnerResult struct {
+	Context struct {
+		APIVersion string `json:"apiVersion"`
+		Slot       int    `json:"slot"`
+	} `json:"context"`
+	Value []struct {
+		Account struct {
+			Data struct {
+				Parsed struct {
+					Info struct {
+						IsNative    bool   `json:"isNative"`
+						Mint        string `json:"mint"`
+						Owner       string `json:"owner"`
+						State       string `json:"state"`
+						TokenAmount struct {
+							Amount         string  `json:"amount"`
+							Decimals       int     `json:"decimals"`
+							UIAmount       float64 `json:"uiAmount"`
+							UIAmountString string  `json:"uiAmountString"`
+						} `json:"tokenAmount"`
+					} `json:"info"`
+					Type string `json:"type"`
+				} `json:"parsed"`
+				Program string `json:"program"`
+				Space   int    `json:"space"`
+			} `json:"data"`
+			Executable bool   `json:"executable"`
+			Lamports   int    `json:"lamports"`
+			Owner      string `json:"owner"`
+			RentEpoch  uint64 `json:"rentEpoch"`
+			Space      int    `json:"space"`
+		} `json:"account"`
+		Pubkey string `json:"pubkey"`
+	} `json:"value"`
+}
+
+type RPCRequestBody struct {
 	BaseRPCBody
 	Params []interface{} `json:"params"`
 }
 
-type GetBalanceResponseBody struct {
-	Jsonrpc string `json:"jsonrpc"`
-	Result  struct {
-		Context struct {
-			APIVersion string `json:"apiVersion"`
-			Slot       int    `json:"slot"`
-		} `json:"context"`
-		Value int `json:"value"`
-	} `json:"result"`
-	ID string `json:"id"`
-}
-
-type GetTokenAccountsByOwnerResponseBody struct {
-	Jsonrpc string `json:"jsonrpc"`
-	Result  struct {
-		Context struct {
-			APIVersion string `json:"apiVersion"`
-			Slot       int    `json:"slot"`
-		} `json:"context"`
-		Value []struct {
-			Account struct {
-				Data struct {
-					Parsed struct {
-						Info struct {
-							IsNative    bool   `json:"isNative"`
-							Mint        string `json:"mint"`
-							Owner       string `json:"owner"`
-							State       string `json:"state"`
-							TokenAmount struct {
-								Amount         string  `json:"amount"`
-								Decimals       int     `json:"decimals"`
-								UIAmount       float64 `json:"uiAmount"`
-								UIAmountString string  `json:"uiAmountString"`
-							} `json:"tokenAmount"`
-						} `json:"info"`
-						Type string `json:"type"`
-					} `json:"parsed"`
-					Program string `json:"program"`
-					Space   int    `json:"space"`
-				} `json:"data"`
-				Executable bool   `json:"executable"`
-				Lamports   int    `json:"lamports"`
-				Owner      string `json:"owner"`
-				RentEpoch  int64  `json:"rentEpoch"`
-				Space      int    `json:"space"`
-			} `json:"account"`
-			Pubkey string `json:"pubkey"`
-		} `json:"value"`
-	} `json:"result"`
-	ID int `json:"id"`
-}
-
-type SendTransactionResponse struct {
-	Jsonrpc string `json:"jsonrpc"`
-	Result  string `json:"result"`
-	ID      int    `json:"id"`
+type SignatureInfo struct {
+	Signature string      `json:"signature"`
+	Slot      uint64      `json:"slot"`
+	Err       interface{} `json:"err"`
+	BlockTime *int64      `json:"blockTime"`
+}
+
+type GetSignaturesForAddressOptions struct {
+	Limit      int    `json:"limit,omitempty"`
+	Before     string `json:"before,omitempty"`
+	Until      string `json:"until,omitempty"`
+	Commitment string `json:"commitment,omitempty"`
+}
+
+type CompiledInnerInstruction struct {
+	ProgramIdIndex uint16   `json:"programIdIndex"`
+	Accounts       []uint16 `json:"accounts"`
+	Data           string   `json:"data"` // base58 encoded
+}
+
+type TokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UiTokenAmount struct {
+		Amount   string `json:"amount"`
+		Decimals int    `json:"decimals"`
+	} `json:"uiTokenAmount"`
+}
+
+type TransactionMeta struct {
+	Err                  interface{}    `json:"err"`
+	Fee                  uint64         `json:"fee"`
+	LogMessages          []string       `json:"logMessages"`
+	PreBalances          []uint64       `json:"preBalances"`
+	PostBalances         []uint64       `json:"postBalances"`
+	PreTokenBalances     []TokenBalance `json:"preTokenBalances"`
+	PostTokenBalances    []TokenBalance `json:"postTokenBalances"`
+	ComputeUnitsConsumed *uint64        `json:"computeUnitsConsumed"`
+	InnerInstructions    []struct {
+		Index        int                        `json:"index"`
+		Instructions []CompiledInnerInstruction `json:"instructions"`
+	} `json:"innerInstructions"`
+
+	// accounts resolved from address lookup tables of v0 transactions
+	LoadedAddresses struct {
+		Writable []string `json:"writable"`
+		Readonly []string `json:"readonly"`
+	} `json:"loadedAddresses"`
+}
+
+type GetTransactionResult struct {
+	Slot        uint64          `json:"slot"`
+	BlockTime   *int64          `json:"blockTime"`
+	Meta        TransactionMeta `json:"meta"`
+	Transaction json.RawMessage `json:"transaction"`
+}
+
+type SignatureStatus struct {
+	Slot               uint64      `json:"slot"`
+	Confirmations      *uint64     `json:"confirmations"`
+	Err                interface{} `json:"err"`
+	ConfirmationStatus string      `json:"confirmationStatus"` // processed, confirmed or finalized
+}
+
+type GetSignatureStatusesResult struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value []*SignatureStatus `json:"value"` // nil entries are unknown signatures
+}
+
+// AccountInfo is an account fetched with base64 encoding, Data holds the encoded bytes and the encoding
+type AccountInfo struct {
+	Data       []string `json:"data"`
+	Owner      string   `json:"owner"`
+	Lamports   uint64   `json:"lamports"`
+	Executable bool     `json:"executable"`
+}
+
+type GetAccountInfoResult struct {
+	Context struct {
+		APIVersion string `json:"apiVersion"`
+		Slot       int    `json:"slot"`
+	} `json:"context"`
+	Value *AccountInfo `json:"value"`
+}
+
+type TokenAccountBalance struct {
+	Address  string `json:"address"`
+	Amount   string `json:"amount"`
+	Decimals int    `json:"decimals"`
+}
+
+type GetTokenLargestAccountsResult struct {
+	Context struct {
+		APIVersion string `json:"apiVersion"`
+		Slot       int    `json:"slot"`
+	} `json:"context"`
+	Value []TokenAccountBalance `json:"value"`
+}
+
+type GetMultipleTokenAccountsResult struct {
+	Context struct {
+		APIVersion string `json:"apiVersion"`
+		Slot       int    `json:"slot"`
+	} `json:"context"`
+	Value []*struct {
+		Data struct {
+			Parsed struct {
+				Info struct {
+					Mint  string `json:"mint"`
+					Owner string `json:"owner"`
+				} `json:"info"`
+			} `json:"parsed"`
+		} `json:"data"`
+	} `json:"value"`
+}
+
+type PrioritizationFee struct {
+	Slot              uint64 `json:"slot"`
+	PrioritizationFee uint64 `json:"prioritizationFee"`
+}
+
+type SimulateTransactionResult struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value struct {
+		Err           interface{}    `json:"err"`
+		Logs          []string       `json:"logs"`
+		UnitsConsumed *uint64        `json:"unitsConsumed"`
+		Accounts      []*AccountInfo `json:"accounts"` // state after the transaction of the requested addresses, base64 encoded
+	} `json:"value"`
 }
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"solana-bot/helius"
+	"time"
+)
+
+// FileSource replays a newline-delimited JSON file, either plain logsSubscribe frames or
+// capture records written by helius.FrameRecorder. Capture records are replayed at their
+// original pace divided by speed, a speed of 0 replays everything as fast as possible.
+type FileSource struct {
+	*emitter
+
+	path  string
+	speed float64
+	file  *os.File
+}
+
+func (s *FileSource) Start() error {
+	file, err := os.Open(s.path)
+
+	if err != nil {
+		return fmt.Errorf("FileSource: failed to open %s %w", s.path, err)
+	}
+
+	s.file = file
+	s.wg.Add(1)
+
+	go s.replay()
+
+	s.closeWhenDone()
+
+	return nil
+}
+
+func (s *FileSource) Stop() {
+	s.stop()
+}
+
+// parseLine returns the raw frame of a line and its receive time when the line is a capture record
+func parseLine(line []byte) ([]byte, *time.Time) {
+	var record helius.CaptureRecord
+
+	err := json.Unmarshal(line, &record)
+
+	if err != nil || len(record.Frame) == 0 {
+		return line, nil
+	}
+
+	receivedAt := time.UnixMilli(record.ReceivedAt)
+
+	return record.Frame, &receivedAt
+}
+
+// wait sleeps until the record is due, returns false if the source was stopped in the meantime
+func (s *FileSource) wait(replayStart time.Time, firstReceivedAt, receivedAt time.Time) bool {
+	if s.speed <= 0 {
+		return true
+	}
+
+	offset := time.Duration(float64(receivedAt.Sub(firstReceivedAt)) / s.speed)
+	delay := time.Until(replayStart.Add(offset))
+
+	if delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-s.done:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+func (s *FileSource) replay() {
+	defer s.wg.Done()
+	defer s.file.Close()
+
+	scanner := bufio.NewScanner(s.file)
+	// frames with many log lines easily exceed the default 64KB token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	count := 0
+	replayStart := time.Now()
+	var firstReceivedAt *time.Time
+
+	for scanner.Scan() {
+		frame, receivedAt := parseLine(scanner.Bytes())
+
+		ev, ok := ParseLogSubscribeMessage(frame)
+
+		if !ok {
+			continue
+		}
+
+		if receivedAt != nil {
+			if firstReceivedAt == nil {
+				firstReceivedAt = receivedAt
+			}
+
+			if !s.wait(replayStart, *firstReceivedAt, *receivedAt) {
+				return
+			}
+		}
+
+		if !s.emit(ev) {
+			return
+		}
+
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println("FileSource:", err)
+	}
+
+	log.Printf("FileSource: Replayed %d events from %s in %s \n", count, s.path, time.Since(replayStart))
+}
+
+func NewFileSource(path string, speed float64) *FileSource {
+	return &FileSource{
+		emitter: newEmitter(),
+		path:    path,
+		speed:   speed,
+	}
+}
@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	"log"
+	"solana-bot/helius"
+	"time"
+)
+
+// PollingSource discovers new transactions by polling getSignaturesForAddress,
+// it only needs a plain JSON-RPC endpoint
+type PollingSource struct {
+	*emitter
+
+	h         *helius.HttpClient
+	addresses []string
+	interval  time.Duration
+	scan      ScanOptions
+
+	// newest signature seen per address
+	cursors map[string]string
+}
+
+func (s *PollingSource) Start() error {
+	s.wg.Add(1)
+
+	go s.poll()
+
+	s.closeWhenDone()
+
+	return nil
+}
+
+func (s *PollingSource) Stop() {
+	s.stop()
+}
+
+func (s *PollingSource) poll() {
+	defer s.wg.Done()
+
+	for !s.stopped() {
+
+		for _, address := range s.addresses {
+			if !s.pollAddress(address) {
+				return
+			}
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// pollAddress emits every transaction since the last poll, returns false if the source was stopped
+func (s *PollingSource) pollAddress(address string) bool {
+
+	cursor, seen := s.cursors[address]
+
+	if !seen {
+		// start from the tip, history is the job of a backfill
+		latest, err := s.h.GetSignaturesForAddress(address, helius.GetSignaturesForAddressOptions{
+			Limit:      1,
+			Commitment: commitment,
+		})
+
+		if err != nil {
+			log.Println("PollingSource:", err)
+
+			return true
+		}
+
+		if len(latest) > 0 {
+			s.cursors[address] = latest[0].Signature
+		}
+
+		return true
+	}
+
+	signatures, err := scanSignatures(s.h, address, cursor, 0, s.scan)
+
+	// a scan that failed partway may have missed signatures in between, the cursor stays so they
+	// are scanned again with the next poll, what was emitted already is deduplicated downstream
+	complete := err == nil
+
+	if err != nil {
+		log.Println("PollingSource:", err)
+	}
+
+	for _, sig := range signatures {
+		ev, ok := fetchEvent(s.h, sig)
+
+		// the cursor stops before a transaction that could not be fetched, it and the ones after
+		// it are fetched again with the next poll
+		if !ok {
+			break
+		}
+
+		if !s.emit(ev) {
+			return false
+		}
+
+		if complete {
+			s.cursors[address] = sig.Signature
+		}
+	}
+
+	return true
+}
+
+func NewPollingSource(h *helius.HttpClient, addresses []string, interval time.Duration, scan ScanOptions) *PollingSource {
+	return &PollingSource{
+		emitter:   newEmitter(),
+		h:         h,
+		addresses: addresses,
+		interval:  interval,
+		scan:      scan,
+		cursors:   make(map[string]string),
+	}
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"log"
+	"solana-bot/helius"
+)
+
+const (
+	commitment = "finalized"
+
+	defaultPageSize = 1000
+	defaultMaxPages = 10
+)
+
+func (o ScanOptions) withDefaults() ScanOptions {
+	if o.PageSize <= 0 || o.PageSize > defaultPageSize {
+		o.PageSize = defaultPageSize
+	}
+
+	if o.MaxPages <= 0 {
+		o.MaxPages = defaultMaxPages
+	}
+
+	return o
+}
+
+// scanSignatures pages backwards through the successful signatures of address until it reaches
+// the until signature (exclusive) or a slot lower than fromSlot, and returns them oldest first
+func scanSignatures(h *helius.HttpClient, address string, until string, fromSlot uint64, opts ScanOptions) ([]helius.SignatureInfo, error) {
+
+	opts = opts.withDefaults()
+
+	var result []helius.SignatureInfo
+	before := ""
+
+	for page := 0; page < opts.MaxPages; page++ {
+
+		signatures, err := h.GetSignaturesForAddress(address, helius.GetSignaturesForAddressOptions{
+			Limit:      opts.PageSize,
+			Before:     before,
+			Until:      until,
+			Commitment: commitment,
+		})
+
+		if err != nil {
+			return reverse(result), err
+		}
+
+		for _, sig := range signatures {
+
+			// several transactions share a slot, so fromSlot itself is scanned again
+			if sig.Slot < fromSlot {
+				return reverse(result), nil
+			}
+
+			before = sig.Signature
+
+			if sig.Err == nil {
+				result = append(result, sig)
+			}
+		}
+
+		if len(signatures) < opts.PageSize {
+			return reverse(result), nil
+		}
+	}
+
+	log.Printf("scanSignatures: Stopped after %d pages for %s \n", opts.MaxPages, address)
+
+	return reverse(result), nil
+}
+
+// fetchEvent loads the logs of a signature with getTransaction
+func fetchEvent(h *helius.HttpClient, sig helius.SignatureInfo) (LogEvent, bool) {
+
+	tx, err := h.GetTransaction(sig.Signature, commitment)
+
+	if err != nil {
+		log.Println("fetchEvent:", err)
+
+		return LogEvent{}, false
+	}
+
+	if tx == nil {
+		return LogEvent{}, false
+	}
+
+	return LogEvent{
+		Slot:      tx.Slot,
+		Signature: sig.Signature,
+		Logs:      tx.Meta.LogMessages,
+	}, true
+}
+
+func reverse(signatures []helius.SignatureInfo) []helius.SignatureInfo {
+	for i, j := 0, len(signatures)-1; i < j; i, j = i+1, j-1 {
+		signatures[i], signatures[j] = signatures[j], signatures[i]
+	}
+
+	return signatures
+}
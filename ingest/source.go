@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"encoding/json"
+	"solana-bot/helius"
+	"sync"
+	"time"
+)
+
+// LogEvent is a program log notification, normalized across event sources
+type LogEvent struct {
+	Slot      uint64
+	Signature string
+	Logs      []string
+}
+
+// EventSource produces log events for the engine. Events is closed once the source has stopped
+// and every pending event has been delivered.
+type EventSource interface {
+	Start() error
+	Stop()
+	Events() <-chan LogEvent
+}
+
+// ScanOptions bounds how far back signatures are paged when polling or backfilling
+type ScanOptions struct {
+	PageSize int
+	MaxPages int
+}
+
+// ParseLogSubscribeMessage converts a raw logsSubscribe frame into a LogEvent,
+// frames that are not log notifications (e.g. the subscription confirmation) are reported as not ok
+func ParseLogSubscribeMessage(frame []byte) (LogEvent, bool) {
+	var m helius.LogSubscribeMessage
+
+	err := json.Unmarshal(frame, &m)
+
+	if err != nil || len(m.Params.Result.Value.Signature) == 0 {
+		return LogEvent{}, false
+	}
+
+	return LogEvent{
+		Slot:      m.Params.Result.Context.Slot,
+		Signature: m.Params.Result.Value.Signature,
+		Logs:      m.Params.Result.Value.Logs,
+	}, true
+}
+
+// emitter holds the channel plumbing shared by every source
+type emitter struct {
+	events chan LogEvent
+	done   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+func newEmitter() *emitter {
+	return &emitter{
+		events: make(chan LogEvent, 1024),
+		done:   make(chan struct{}),
+	}
+}
+
+func (e *emitter) Events() <-chan LogEvent {
+	return e.events
+}
+
+// emit delivers the event, returns false if the source was stopped in the meantime
+func (e *emitter) emit(ev LogEvent) bool {
+	select {
+	case e.events <- ev:
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+func (e *emitter) stop() {
+	e.once.Do(func() {
+		close(e.done)
+	})
+}
+
+// wait sleeps for d, returns false if the source was stopped in the meantime
+func (e *emitter) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+func (e *emitter) stopped() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeWhenDone closes the events channel once every producer goroutine has returned
+func (e *emitter) closeWhenDone() {
+	go func() {
+		e.wg.Wait()
+		close(e.events)
+	}()
+}
@@ -0,0 +1,168 @@
+package ingest
+
+import (
+	"log"
+	"solana-bot/helius"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	backfillRetries    = 3
+	backfillRetryDelay = 2 * time.Second
+)
+
+// WebsocketSource streams logsSubscribe notifications and backfills the gap after every reconnect
+type WebsocketSource struct {
+	*emitter
+
+	streamer  *helius.Streamer
+	h         *helius.HttpClient
+	addresses []string
+	backfill  ScanOptions
+
+	lastSlot    atomic.Uint64
+	backfilling atomic.Bool
+}
+
+func (s *WebsocketSource) Start() error {
+	s.wg.Add(2)
+
+	go s.readFrames()
+	go s.watchStates()
+	go s.streamer.Run()
+
+	s.closeWhenDone()
+
+	return nil
+}
+
+func (s *WebsocketSource) Stop() {
+	s.stop()
+	s.streamer.Close()
+}
+
+func (s *WebsocketSource) readFrames() {
+	defer s.wg.Done()
+
+	for frame := range s.streamer.GetMessageChannel() {
+		ev, ok := ParseLogSubscribeMessage(frame)
+
+		if !ok {
+			continue
+		}
+
+		s.trackSlot(ev.Slot)
+
+		if !s.emit(ev) {
+			return
+		}
+	}
+}
+
+func (s *WebsocketSource) watchStates() {
+	defer s.wg.Done()
+
+	disconnected := false
+
+	for state := range s.streamer.States() {
+		switch state {
+		case helius.StateSubscribed:
+			if disconnected {
+				disconnected = false
+
+				// recover whatever was emitted while we were not listening
+				s.wg.Add(1)
+				go s.backfillFrom(s.lastSlot.Load())
+			}
+		case helius.StateDegraded:
+			disconnected = true
+			log.Println("WebsocketSource: Stream is degraded, live events may be missed")
+		case helius.StateStopped:
+			log.Println("WebsocketSource: Stream stopped, no more live events will be received")
+		}
+	}
+}
+
+// trackSlot records the highest slot seen on the live stream
+func (s *WebsocketSource) trackSlot(slot uint64) {
+	for {
+		current := s.lastSlot.Load()
+
+		if slot <= current || s.lastSlot.CompareAndSwap(current, slot) {
+			return
+		}
+	}
+}
+
+func (s *WebsocketSource) backfillFrom(fromSlot uint64) {
+	defer s.wg.Done()
+
+	if fromSlot == 0 {
+		log.Println("backfillFrom: No slot seen yet, nothing to backfill")
+
+		return
+	}
+
+	// only one backfill at a time, the next one will cover the remaining gap
+	if !s.backfilling.CompareAndSwap(false, true) {
+		log.Println("backfillFrom: Backfill already running")
+
+		return
+	}
+
+	defer s.backfilling.Store(false)
+
+	for _, address := range s.addresses {
+
+		log.Printf("backfillFrom: Backfilling %s from slot %d \n", address, fromSlot)
+
+		signatures, err := scanSignatures(s.h, address, "", fromSlot, s.backfill)
+
+		if err != nil {
+			log.Println("backfillFrom:", err)
+		}
+
+		recovered := 0
+		pending := signatures
+
+		// transactions that could not be fetched are tried again after a growing delay
+		for attempt := 0; attempt <= backfillRetries && len(pending) > 0; attempt++ {
+
+			if attempt > 0 && !s.wait(backfillRetryDelay<<(attempt-1)) {
+				return
+			}
+
+			var failed []helius.SignatureInfo
+
+			for _, sig := range pending {
+				ev, ok := fetchEvent(s.h, sig)
+
+				if !ok {
+					failed = append(failed, sig)
+					continue
+				}
+
+				if !s.emit(ev) {
+					return
+				}
+
+				recovered++
+			}
+
+			pending = failed
+		}
+
+		log.Printf("backfillFrom: Scanned %d signatures for %s, recovered %d events, dropped %d \n", len(signatures), address, recovered, len(pending))
+	}
+}
+
+func NewWebsocketSource(streamer *helius.Streamer, h *helius.HttpClient, addresses []string, backfill ScanOptions) *WebsocketSource {
+	return &WebsocketSource{
+		emitter:   newEmitter(),
+		streamer:  streamer,
+		h:         h,
+		addresses: addresses,
+		backfill:  backfill,
+	}
+}
@@ -34,14 +34,25 @@ func (c *Client) GetQuote(params GetQuoteParams) *GetQuoteResponse {
 
 }
 
-func (c *Client) BuildSwapTransaction(quote *GetQuoteResponse, publicKey string) *BuildSwapTransactionResponseBody {
-
-	body, err := json.Marshal(BuildSwapTransactionRequestBody{
-		QuoteResponse:           *quote,
-		UserPublicKey:           publicKey,
-		DynamicComputeUnitLimit: true,
-		DynamicSlippage:         true,
-	})
+func (c *Client) BuildSwapTransaction(quote *GetQuoteResponse, publicKey string, fee PriorityFee) *BuildSwapTransactionResponseBody {
+
+	request := BuildSwapTransactionRequestBody{
+		QuoteResponse:                 *quote,
+		UserPublicKey:                 publicKey,
+		DynamicComputeUnitLimit:       true,
+		DynamicSlippage:               true,
+		ComputeUnitPriceMicroLamports: fee.ComputeUnitPriceMicroLamports,
+	}
+
+	if fee.ComputeUnitPriceMicroLamports == 0 && fee.MaxLamports > 0 {
+		var auto priorityLevelWithMaxLamports
+		auto.PriorityLevelWithMaxLamports.MaxLamports = fee.MaxLamports
+		auto.PriorityLevelWithMaxLamports.PriorityLevel = fee.PriorityLevel
+
+		request.PrioritizationFeeLamports = auto
+	}
+
+	body, err := json.Marshal(request)
 
 	if err != nil {
 		log.Println("failed to json.Marshal body", err)
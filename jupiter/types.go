@@ -56,6 +56,24 @@ type BuildSwapTransactionRequestBody struct {
 	UserPublicKey           string           `json:"userPublicKey"`
 	DynamicComputeUnitLimit bool             `json:"dynamicComputeUnitLimit"`
 	DynamicSlippage         bool             `json:"dynamicSlippage"`
+
+	ComputeUnitPriceMicroLamports uint64      `json:"computeUnitPriceMicroLamports,omitempty"`
+	PrioritizationFeeLamports     interface{} `json:"prioritizationFeeLamports,omitempty"`
+}
+
+// PriorityFee sets the compute unit price of a swap transaction, either a fixed price or jupiter's
+// own estimate capped at MaxLamports. The zero value leaves it to jupiter's default.
+type PriorityFee struct {
+	ComputeUnitPriceMicroLamports uint64
+	MaxLamports                   uint64
+	PriorityLevel                 string // medium, high or veryHigh
+}
+
+type priorityLevelWithMaxLamports struct {
+	PriorityLevelWithMaxLamports struct {
+		MaxLamports   uint64 `json:"maxLamports"`
+		PriorityLevel string `json:"priorityLevel"`
+	} `json:"priorityLevelWithMaxLamports"`
 }
 
 type BuildSwapTransactionResponseBody struct {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -22,12 +23,21 @@ func getConfig() *config.Config {
 
 	json.NewDecoder(file).Decode(&config)
 
+	err = config.Trader.Validate()
+
+	if err != nil {
+		log.Fatal("Invalid config ", err)
+	}
+
 	return &config
 
 }
 
 func main() {
 
+	resetKillSwitch := flag.Bool("reset-kill-switch", false, "resume buying after the risk manager tripped the kill switch")
+	flag.Parse()
+
 	exitChan := make(chan os.Signal, 1)
 	signal.Notify(exitChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -35,6 +45,10 @@ func main() {
 
 	e := engine.New(config)
 
+	if *resetKillSwitch {
+		e.ResetKillSwitch()
+	}
+
 	go e.Start()
 
 	signal := <-exitChan
@@ -0,0 +1,99 @@
+package mints
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"sync"
+)
+
+// Service resolves mint accounts, decimals and the authorities never change for a mint
+// address so lookups are served from memory, then the mints table, then the chain
+type Service struct {
+	h  *helius.HttpClient
+	db *db.SqlClient
+
+	cache map[string]db.MintEntity
+	mu    sync.RWMutex
+}
+
+func (s *Service) Get(address string) (*db.MintEntity, error) {
+	s.mu.RLock()
+	m, ok := s.cache[address]
+	s.mu.RUnlock()
+
+	if ok {
+		return &m, nil
+	}
+
+	if stored := s.db.GetMint(address); stored != nil {
+		s.store(*stored)
+
+		return stored, nil
+	}
+
+	return s.Refresh(address)
+}
+
+// Refresh reads the mint account from the chain and updates the cache, e.g. to see revoked authorities
+func (s *Service) Refresh(address string) (*db.MintEntity, error) {
+	account, data, err := s.h.GetAccountInfo(address)
+
+	if err != nil {
+		return nil, fmt.Errorf("Refresh: failed to get mint account %s: %w", address, err)
+	}
+
+	if account == nil {
+		return nil, fmt.Errorf("Refresh: mint account %s not found", address)
+	}
+
+	m, err := ParseMint(address, account.Owner, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Refresh: Mint %s has %d decimals \n", address, m.Decimals)
+
+	s.db.UpsertMint(m)
+	s.store(m)
+
+	return &m, nil
+}
+
+func (s *Service) Decimals(address string) (int, error) {
+	m, err := s.Get(address)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return m.Decimals, nil
+}
+
+func (s *Service) store(m db.MintEntity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache[m.Address] = m
+}
+
+// ToUiAmount converts atomic units into whole tokens
+func ToUiAmount(amount uint64, decimals int) float64 {
+	return float64(amount) / math.Pow(10, float64(decimals))
+}
+
+// ToAtomic converts whole tokens into atomic units
+func ToAtomic(amount float64, decimals int) uint64 {
+	return uint64(math.Round(amount * math.Pow(10, float64(decimals))))
+}
+
+func New(h *helius.HttpClient, sc *db.SqlClient) *Service {
+	return &Service{
+		h:     h,
+		db:    sc,
+		cache: make(map[string]db.MintEntity),
+	}
+}
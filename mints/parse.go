@@ -0,0 +1,165 @@
+package mints
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"solana-bot/db"
+	"solana-bot/utils"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	mintSize = 82
+
+	// token-2022 pads mints to the size of a token account, followed by the account type and the extensions
+	accountTypeOffset = 165
+	accountTypeMint   = 1
+)
+
+// token-2022 extension types, see spl_token_2022::extension::ExtensionType
+const (
+	extensionTransferFeeConfig = 1
+	extensionTransferHook      = 14
+	extensionTokenMetadata     = 19
+)
+
+var extensionNames = map[uint16]string{
+	1:  "transferFeeConfig",
+	3:  "mintCloseAuthority",
+	4:  "confidentialTransferMint",
+	6:  "defaultAccountState",
+	9:  "nonTransferable",
+	10: "interestBearingConfig",
+	12: "permanentDelegate",
+	14: "transferHook",
+	16: "confidentialTransferFeeConfig",
+	18: "metadataPointer",
+	19: "tokenMetadata",
+	20: "groupPointer",
+	21: "tokenGroup",
+	22: "groupMemberPointer",
+	23: "tokenGroupMember",
+	25: "scaledUiAmount",
+	26: "pausable",
+}
+
+// ErrNotAMint means the account is not an spl token or token-2022 mint, it will never parse
+var ErrNotAMint = errors.New("account is not a mint")
+
+// ParseMint decodes an spl token or token-2022 mint account
+func ParseMint(address, owner string, data []byte) (db.MintEntity, error) {
+	m := db.MintEntity{Address: address, ProgramId: owner}
+
+	if owner != solana.TokenProgramID.String() && owner != solana.Token2022ProgramID.String() {
+		return m, fmt.Errorf("ParseMint: %s is owned by %s: %w", address, owner, ErrNotAMint)
+	}
+
+	if len(data) < mintSize || (len(data) > mintSize && len(data) <= accountTypeOffset) {
+		return m, fmt.Errorf("ParseMint: %s has %d bytes: %w", address, len(data), ErrNotAMint)
+	}
+
+	m.MintAuthority = parseOptionalKey(data[0:36])
+	m.Supply = binary.LittleEndian.Uint64(data[36:44])
+	m.Decimals = int(data[44])
+	m.FreezeAuthority = parseOptionalKey(data[46:82])
+
+	if len(data) == mintSize {
+		return m, nil
+	}
+
+	if data[accountTypeOffset] != accountTypeMint {
+		return m, fmt.Errorf("ParseMint: %s has account type %d: %w", address, data[accountTypeOffset], ErrNotAMint)
+	}
+
+	var extensions []string
+
+	for offset := accountTypeOffset + 1; offset+4 <= len(data); {
+		extType := binary.LittleEndian.Uint16(data[offset:])
+		length := int(binary.LittleEndian.Uint16(data[offset+2:]))
+		offset += 4
+
+		// the rest of the account is unused space
+		if extType == 0 || offset+length > len(data) {
+			break
+		}
+
+		value := data[offset : offset+length]
+		offset += length
+
+		name, ok := extensionNames[extType]
+
+		if !ok {
+			name = fmt.Sprintf("unknown(%d)", extType)
+		}
+
+		extensions = append(extensions, name)
+
+		switch extType {
+		case extensionTransferFeeConfig:
+			// authorities (64), withheld amount (8), older fee (18), newer fee: epoch (8), maximum fee (8), basis points (2)
+			if len(value) >= 108 {
+				bps := int(binary.LittleEndian.Uint16(value[106:108]))
+				m.TransferFeeBps = &bps
+			}
+		case extensionTransferHook:
+			// authority (32), program id (32)
+			if len(value) >= 64 {
+				m.TransferHookProgram = parseKey(value[32:64])
+			}
+		case extensionTokenMetadata:
+			// update authority (32), mint (32), then borsh strings name, symbol, uri
+			if len(value) >= 64 {
+				rest := value[64:]
+				m.Name, rest = parseString(rest)
+				m.Symbol, _ = parseString(rest)
+			}
+		}
+	}
+
+	if len(extensions) > 0 {
+		encoded := utils.ToString(extensions)
+		m.Extensions = &encoded
+	}
+
+	return m, nil
+}
+
+// parseOptionalKey reads a COption<Pubkey>, a 4 byte tag followed by the key
+func parseOptionalKey(data []byte) *string {
+	if binary.LittleEndian.Uint32(data[0:4]) == 0 {
+		return nil
+	}
+
+	return parseKey(data[4:36])
+}
+
+// parseKey returns nil for the zero key, which token-2022 uses for unset optional keys
+func parseKey(data []byte) *string {
+	key := solana.PublicKeyFromBytes(data)
+
+	if key.IsZero() {
+		return nil
+	}
+
+	s := key.String()
+
+	return &s
+}
+
+func parseString(data []byte) (*string, []byte) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[0:4]))
+
+	if 4+length > len(data) {
+		return nil, nil
+	}
+
+	s := string(data[4 : 4+length])
+
+	return &s, data[4+length:]
+}
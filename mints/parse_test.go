@@ -0,0 +1,132 @@
+package mints
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"solana-bot/helius"
+	"testing"
+)
+
+// loadAccount reads a getAccountInfo value and decodes its base64 data
+func loadAccount(t *testing.T, path string) (*helius.AccountInfo, []byte) {
+	t.Helper()
+
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var account helius.AccountInfo
+
+	if err := json.Unmarshal(raw, &account); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(account.Data[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &account, data
+}
+
+func stringOf(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return *s
+}
+
+func TestParseMintSplToken(t *testing.T) {
+	account, data := loadAccount(t, "testdata/spl_mint.json")
+
+	m, err := ParseMint("spl", account.Owner, data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Decimals != 6 || m.Supply != 1_000_000_000_000_000 {
+		t.Errorf("decimals = %d, supply = %d, want 6 and 10^15", m.Decimals, m.Supply)
+	}
+
+	if got := stringOf(m.MintAuthority); got != "GEvvgbji7LzNx7rhdi5qE1Dzo8aG1Cb8wrRcpfsXPEAC" {
+		t.Errorf("mint authority = %s", got)
+	}
+
+	if m.FreezeAuthority != nil || m.Extensions != nil || m.TransferFeeBps != nil {
+		t.Errorf("freeze authority = %s, extensions = %s, transfer fee = %v, want none", stringOf(m.FreezeAuthority), stringOf(m.Extensions), m.TransferFeeBps)
+	}
+}
+
+// The token-2022 mint has a transfer fee going from 100 to 250 bps, a metadata pointer, a transfer hook and
+// the token metadata, the mint authority is revoked.
+func TestParseMintToken2022(t *testing.T) {
+	account, data := loadAccount(t, "testdata/token2022_mint.json")
+
+	m, err := ParseMint("t22", account.Owner, data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Decimals != 9 || m.Supply != 500_000_000_000 || m.MintAuthority != nil {
+		t.Errorf("decimals = %d, supply = %d, mint authority = %s, want 9, 5*10^11 and none", m.Decimals, m.Supply, stringOf(m.MintAuthority))
+	}
+
+	if got := stringOf(m.FreezeAuthority); got != "Ab5uFH86WgByiDyzbqiuXTMy9LHW5EGdBFzKYxLBBPr6" {
+		t.Errorf("freeze authority = %s", got)
+	}
+
+	// the newer fee is the one that applies
+	if m.TransferFeeBps == nil || *m.TransferFeeBps != 250 {
+		t.Errorf("transfer fee = %v, want 250 bps", m.TransferFeeBps)
+	}
+
+	if got := stringOf(m.TransferHookProgram); got != "CFAk3a9114wRJpUip2QZ7uVPEry3obJ4LHcfwaNkGiQy" {
+		t.Errorf("transfer hook program = %s", got)
+	}
+
+	if stringOf(m.Name) != "Fee Token" || stringOf(m.Symbol) != "FEE" {
+		t.Errorf("name = %s, symbol = %s, want Fee Token and FEE", stringOf(m.Name), stringOf(m.Symbol))
+	}
+
+	want := `["transferFeeConfig","metadataPointer","transferHook","tokenMetadata"]`
+
+	if got := stringOf(m.Extensions); got != want {
+		t.Errorf("extensions = %s, want %s", got, want)
+	}
+}
+
+func TestParseMintRejects(t *testing.T) {
+	splAccount, spl := loadAccount(t, "testdata/spl_mint.json")
+	t22Account, t22 := loadAccount(t, "testdata/token2022_mint.json")
+
+	// a token account has the account type 2
+	tokenAccount := append([]byte{}, t22...)
+	tokenAccount[accountTypeOffset] = 2
+
+	tests := []struct {
+		name  string
+		owner string
+		data  []byte
+	}{
+		{"other program", "11111111111111111111111111111111", spl},
+		{"too short", splAccount.Owner, spl[:mintSize-1]},
+		{"token account size", splAccount.Owner, make([]byte, accountTypeOffset)},
+		{"token account type", t22Account.Owner, tokenAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMint("x", tt.owner, tt.data); !errors.Is(err, ErrNotAMint) {
+				t.Errorf("err = %v, want ErrNotAMint", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package paper
+
+import (
+	"fmt"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/jupiter"
+	"solana-bot/mints"
+	"solana-bot/wallet"
+	"strconv"
+	"time"
+)
+
+// network fee of a swap signed by the wallet alone
+const lamportsPerSignature = 5000
+
+// Wallet reads balances from the paper ledger instead of the chain
+type Wallet struct {
+	db         *db.SqlClient
+	m          *mints.Service
+	nativeMint string
+}
+
+func (w *Wallet) GetBalance() (int, error) {
+	bal, err := w.db.GetPaperBalance(w.nativeMint)
+
+	return int(bal), err
+}
+
+func (w *Wallet) GetTokenHolding(mint string) (wallet.TokenHolding, error) {
+	var holding wallet.TokenHolding
+
+	bal, err := w.db.GetPaperBalance(mint)
+
+	if err != nil {
+		return holding, err
+	}
+
+	decimals, err := w.m.Decimals(mint)
+
+	if err != nil {
+		return holding, err
+	}
+
+	holding.Amount = int(bal)
+	holding.Decimals = decimals
+
+	return holding, nil
+}
+
+// SimulateFill fills a swap at the quoted output minus the configured slippage
+func SimulateFill(quote *jupiter.GetQuoteResponse, amount int, c config.PaperConfig) (db.SwapFill, error) {
+	quotedOut, err := strconv.ParseUint(quote.OutAmount, 10, 64)
+
+	if err != nil {
+		return db.SwapFill{}, fmt.Errorf("SimulateFill: invalid quote out amount %q: %w", quote.OutAmount, err)
+	}
+
+	slippage := float64(min(max(c.SlippageBps, 0), 10000)) / 10000
+	outAmount := uint64(float64(quotedOut) * (1 - slippage))
+
+	return db.SwapFill{
+		QuotedOutAmount: quotedOut,
+		InAmount:        uint64(amount),
+		OutAmount:       outAmount,
+		NetworkFee:      lamportsPerSignature,
+		PriorityFee:     c.PriorityFee,
+		SlippageBps:     float64(c.SlippageBps),
+	}, nil
+}
+
+// TxHash returns a unique placeholder signature for a simulated swap
+func TxHash() string {
+	return fmt.Sprintf("paper-%d", time.Now().UnixNano())
+}
+
+// NewWallet seeds the paper ledger with the starting balance the first time paper mode runs
+func NewWallet(c config.PaperConfig, sc *db.SqlClient, m *mints.Service, nativeMint string) *Wallet {
+	sc.SeedPaperLedger(nativeMint, int64(mints.ToAtomic(c.StartingSol, 9)))
+
+	return &Wallet{db: sc, m: m, nativeMint: nativeMint}
+}
@@ -0,0 +1,284 @@
+package portfolio
+
+import (
+	"log"
+	"solana-bot/db"
+	"solana-bot/mints"
+	"time"
+)
+
+const solDecimals = 9
+
+// Position groups the fills of a mint from the first buy until the holding is sold in full
+type Position struct {
+	Mint     string
+	Open     bool
+	OpenedAt time.Time
+	ClosedAt *time.Time
+	Orders   []uint64
+
+	Holding     uint64 // atomic units
+	BoughtSol   float64
+	SoldSol     float64
+	FeesSol     float64
+	CostBasis   float64 // SOL paid for the holding, fees included, reduced proportionally on every sell
+	RealizedPnl float64 // SOL
+
+	// valuation of open positions at the latest market data snapshot, PriceNative is in SOL
+	PriceNative    float64
+	PriceUsd       float64
+	MarketValueSol float64
+	UnrealizedPnl  float64 // SOL
+
+	RealizedPnlUsd   float64 // at the current SOL price
+	UnrealizedPnlUsd float64
+
+	Realizations []Realization
+}
+
+// Realization is the PnL realized by one sell order
+type Realization struct {
+	OrderId uint64
+	At      time.Time
+	PnlSol  float64
+}
+
+type Snapshot struct {
+	Timestamp time.Time
+	Simulated bool
+	Positions []Position
+
+	SolBalance        float64
+	PositionsValueSol float64
+	EquitySol         float64
+	EquityUsd         *float64 // nil without a SOL/USD price
+	SolUsd            float64
+
+	CostBasisSol     float64
+	RealizedPnlSol   float64
+	UnrealizedPnlSol float64
+	RealizedPnlUsd   float64
+	UnrealizedPnlUsd float64
+	OpenPositions    int
+}
+
+type balances interface {
+	GetBalance() (int, error)
+}
+
+// Tracker builds positions from the executed swap orders of live or paper trading
+type Tracker struct {
+	db         *db.SqlClient
+	m          *mints.Service
+	bal        balances
+	nativeMint string
+	simulated  bool
+}
+
+// Positions replays the fills in execution order, using the average cost of the holding as cost basis
+func (t *Tracker) Positions() []Position {
+	var all []*Position
+	open := make(map[string]*Position)
+
+	swaps := t.db.GetExecutedSwaps(t.simulated)
+
+	for _, s := range swaps {
+		fee := 0.0
+
+		if s.TxFee != nil {
+			fee = mints.ToUiAmount(*s.TxFee, solDecimals)
+		}
+
+		switch {
+		case s.FromToken == t.nativeMint:
+			p, ok := open[s.ToToken]
+
+			if !ok {
+				p = &Position{Mint: s.ToToken, Open: true, OpenedAt: *s.ExecutedAt}
+				open[s.ToToken] = p
+				all = append(all, p)
+			}
+
+			sol := mints.ToUiAmount(*s.InAmount, solDecimals)
+
+			p.Orders = append(p.Orders, s.Id)
+			p.Holding += *s.OutAmount
+			p.BoughtSol += sol
+			p.FeesSol += fee
+			p.CostBasis += sol + fee
+
+		case s.ToToken == t.nativeMint:
+			p, ok := open[s.FromToken]
+
+			if !ok || p.Holding == 0 {
+				log.Printf("Positions: Order %d sells %s without an open position \n", s.Id, s.FromToken)
+				continue
+			}
+
+			sold := min(*s.InAmount, p.Holding)
+			sol := mints.ToUiAmount(*s.OutAmount, solDecimals)
+			basis := p.CostBasis * float64(sold) / float64(p.Holding)
+
+			p.Orders = append(p.Orders, s.Id)
+			p.Holding -= sold
+			p.SoldSol += sol
+			p.FeesSol += fee
+			p.CostBasis -= basis
+			p.RealizedPnl += sol - fee - basis
+			p.Realizations = append(p.Realizations, Realization{OrderId: s.Id, At: *s.ExecutedAt, PnlSol: sol - fee - basis})
+
+			if p.Holding == 0 {
+				p.Open = false
+				p.ClosedAt = s.ExecutedAt
+				p.CostBasis = 0
+
+				delete(open, s.FromToken)
+			}
+		}
+	}
+
+	positions := make([]Position, 0, len(all))
+
+	for _, p := range all {
+		if p.Open {
+			t.value(p)
+		}
+
+		positions = append(positions, *p)
+	}
+
+	return positions
+}
+
+// RealizedPnlSince sums the PnL of the sells executed since the given time
+func (t *Tracker) RealizedPnlSince(since time.Time) float64 {
+	var pnl float64
+
+	for _, p := range t.Positions() {
+		for _, r := range p.Realizations {
+			if !r.At.Before(since) {
+				pnl += r.PnlSol
+			}
+		}
+	}
+
+	return pnl
+}
+
+// value marks an open position to the latest market data price. priceNative is in the quote token of
+// the pair, it is the SOL price only for SOL-quoted pairs, other pairs are valued through priceUsd.
+func (t *Tracker) value(p *Position) {
+	m := t.db.GetLatestMarketData(p.Mint)
+
+	if m == nil {
+		return
+	}
+
+	decimals, err := t.m.Decimals(p.Mint)
+
+	if err != nil {
+		log.Println("value:", err)
+
+		return
+	}
+
+	p.PriceUsd = m.PriceUsd
+
+	token := t.db.GetToken(p.Mint)
+
+	switch {
+	case token != nil && token.QuoteMint != nil && *token.QuoteMint == t.nativeMint:
+		p.PriceNative = m.PriceNative
+	case m.PriceUsd > 0:
+		solUsd := t.db.GetSolUsdPrice(t.nativeMint)
+
+		if solUsd <= 0 {
+			log.Printf("value: No SOL/USD price to value %s \n", p.Mint)
+
+			return
+		}
+
+		p.PriceNative = m.PriceUsd / solUsd
+	default:
+		return
+	}
+
+	p.MarketValueSol = mints.ToUiAmount(p.Holding, decimals) * p.PriceNative
+	p.UnrealizedPnl = p.MarketValueSol - p.CostBasis
+}
+
+// Snapshot values the whole portfolio: the SOL balance plus the open positions
+func (t *Tracker) Snapshot() (Snapshot, error) {
+	s := Snapshot{
+		Timestamp: time.Now(),
+		Simulated: t.simulated,
+		Positions: t.Positions(),
+		SolUsd:    t.db.GetSolUsdPrice(t.nativeMint),
+	}
+
+	lamports, err := t.bal.GetBalance()
+
+	if err != nil {
+		return s, err
+	}
+
+	s.SolBalance = mints.ToUiAmount(uint64(max(lamports, 0)), solDecimals)
+
+	for i := range s.Positions {
+		p := &s.Positions[i]
+
+		p.RealizedPnlUsd = p.RealizedPnl * s.SolUsd
+		p.UnrealizedPnlUsd = p.UnrealizedPnl * s.SolUsd
+
+		s.RealizedPnlSol += p.RealizedPnl
+		s.RealizedPnlUsd += p.RealizedPnlUsd
+
+		if p.Open {
+			s.OpenPositions++
+			s.PositionsValueSol += p.MarketValueSol
+			s.CostBasisSol += p.CostBasis
+			s.UnrealizedPnlSol += p.UnrealizedPnl
+			s.UnrealizedPnlUsd += p.UnrealizedPnlUsd
+		}
+	}
+
+	s.EquitySol = s.SolBalance + s.PositionsValueSol
+
+	if s.SolUsd > 0 {
+		equityUsd := s.EquitySol * s.SolUsd
+		s.EquityUsd = &equityUsd
+	}
+
+	return s, nil
+}
+
+// Record takes a snapshot and stores it in portfolio_snapshots
+func (t *Tracker) Record() {
+	s, err := t.Snapshot()
+
+	if err != nil {
+		log.Println("Record: Failed to take portfolio snapshot", err)
+
+		return
+	}
+
+	log.Printf("Record: Equity %.4f SOL, %d open positions, realized %.4f SOL, unrealized %.4f SOL \n",
+		s.EquitySol, s.OpenPositions, s.RealizedPnlSol, s.UnrealizedPnlSol)
+
+	t.db.InsertPortfolioSnapshot(db.PortfolioSnapshotEntity{
+		Timestamp:         s.Timestamp,
+		Simulated:         s.Simulated,
+		SolBalance:        s.SolBalance,
+		PositionsValueSol: s.PositionsValueSol,
+		EquitySol:         s.EquitySol,
+		EquityUsd:         s.EquityUsd,
+		CostBasisSol:      s.CostBasisSol,
+		RealizedPnlSol:    s.RealizedPnlSol,
+		UnrealizedPnlSol:  s.UnrealizedPnlSol,
+		OpenPositions:     s.OpenPositions,
+	})
+}
+
+func New(sc *db.SqlClient, m *mints.Service, bal balances, nativeMint string, simulated bool) *Tracker {
+	return &Tracker{db: sc, m: m, bal: bal, nativeMint: nativeMint, simulated: simulated}
+}
@@ -0,0 +1,133 @@
+package risk
+
+import (
+	"fmt"
+	"log"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/mints"
+	"solana-bot/portfolio"
+	"solana-bot/utils"
+	"time"
+)
+
+type balances interface {
+	GetBalance() (int, error)
+}
+
+// Manager decides whether a buy may be sent, given the open positions, the realized
+// losses of the day, the wallet balance and the recent trading activity
+type Manager struct {
+	c         *config.RiskManagerConfig
+	db        *db.SqlClient
+	p         *portfolio.Tracker
+	bal       balances
+	simulated bool
+}
+
+// CheckBuy returns why the buy order of quantitySol of mint is not allowed, empty when it is.
+// Buys in flight (quoting or submitted) count towards every limit as if they were filled, the caller
+// serializes CheckBuy with the transition of the order to quoting so none of them is missed.
+// An error means a limit could not be evaluated and the order should be retried.
+func (m *Manager) CheckBuy(orderId uint64, mint string, quantitySol float64) (string, error) {
+
+	if state := m.db.GetRiskState(m.simulated); state.KillSwitch {
+		reason := "unknown reason"
+
+		if state.Reason != nil {
+			reason = *state.Reason
+		}
+
+		return "kill switch tripped: " + reason, nil
+	}
+
+	if m.c.MaxSolPerTrade > 0 && quantitySol > m.c.MaxSolPerTrade {
+		return fmt.Sprintf("trade of %g SOL exceeds the maximum of %g SOL per trade", quantitySol, m.c.MaxSolPerTrade), nil
+	}
+
+	if m.c.MaxDailyLossSol > 0 {
+		now := time.Now().UTC()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		if pnl := m.p.RealizedPnlSince(midnight); -pnl >= m.c.MaxDailyLossSol {
+			reason := fmt.Sprintf("realized loss of %.4f SOL today reached the maximum of %g SOL", -pnl, m.c.MaxDailyLossSol)
+
+			log.Println("CheckBuy: Tripping kill switch,", reason)
+			m.db.TripKillSwitch(reason, m.simulated)
+
+			return "kill switch tripped: " + reason, nil
+		}
+	}
+
+	inFlight := m.db.GetInFlightBuys(m.simulated, orderId)
+
+	// quoting buys are not submitted yet, they will be
+	quoting := 0
+	inFlightSol := 0.0
+	inFlightMints := make(map[string]float64)
+
+	for _, o := range inFlight {
+		if o.Status == db.OrderStatusQuoting {
+			quoting++
+		}
+
+		q := float64(utils.Deserialize[db.AmountDetails](*o.AmountDetails).QuantitySol)
+		inFlightSol += q
+		inFlightMints[o.ToToken] += q
+	}
+
+	if m.c.MaxTradesPerHour > 0 {
+		if count := m.db.CountSubmittedSwaps(time.Now().Add(-time.Hour), m.simulated) + quoting; count >= m.c.MaxTradesPerHour {
+			return fmt.Sprintf("%d trades in the last hour reached the maximum of %d", count, m.c.MaxTradesPerHour), nil
+		}
+	}
+
+	if m.c.MaxOpenPositions > 0 || m.c.MaxSolPerToken > 0 {
+		open := make(map[string]bool)
+		exposure := inFlightMints[mint]
+
+		for _, p := range m.p.Positions() {
+			if !p.Open {
+				continue
+			}
+
+			open[p.Mint] = true
+
+			if p.Mint == mint {
+				exposure += p.CostBasis
+			}
+		}
+
+		for inFlightMint := range inFlightMints {
+			open[inFlightMint] = true
+		}
+
+		// adding to an open or in-flight position does not open a new one
+		if m.c.MaxOpenPositions > 0 && !open[mint] && len(open) >= m.c.MaxOpenPositions {
+			return fmt.Sprintf("%d open and in-flight positions reached the maximum of %d", len(open), m.c.MaxOpenPositions), nil
+		}
+
+		if m.c.MaxSolPerToken > 0 && exposure+quantitySol > m.c.MaxSolPerToken {
+			return fmt.Sprintf("exposure of %.4f SOL to %s would exceed the maximum of %g SOL", exposure+quantitySol, mint, m.c.MaxSolPerToken), nil
+		}
+	}
+
+	if m.c.MinSolReserve > 0 {
+		lamports, err := m.bal.GetBalance()
+
+		if err != nil {
+			return "", fmt.Errorf("CheckBuy: failed to get balance: %w", err)
+		}
+
+		// in-flight buys may not have left the balance yet
+		if left := mints.ToUiAmount(uint64(max(lamports, 0)), 9) - inFlightSol - quantitySol; left < m.c.MinSolReserve {
+			return fmt.Sprintf("%.4f SOL left after the trade is below the reserve of %g SOL", left, m.c.MinSolReserve), nil
+		}
+	}
+
+	return "", nil
+}
+
+func New(c *config.RiskManagerConfig, sc *db.SqlClient, p *portfolio.Tracker, bal balances, simulated bool) *Manager {
+	return &Manager{c: c, db: sc, p: p, bal: bal, simulated: simulated}
+}
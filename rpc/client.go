@@ -0,0 +1,259 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"solana-bot/config"
+	"sort"
+	"time"
+)
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultHealthInterval = 30 * time.Second
+)
+
+type request struct {
+	ID      int           `json:"id"`
+	JsonRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type responseError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// Client sends JSON-RPC requests to the best available endpoint and fails over to the
+// next one on transport errors, timeouts, 429 and 5xx responses
+type Client struct {
+	endpoints      []*endpoint
+	httpClient     *http.Client
+	healthInterval time.Duration
+}
+
+// candidates returns the endpoints to try in order: available ones by score, then the ones
+// cooling down or unhealthy, so a request is never dropped without trying
+func (c *Client) candidates() []*endpoint {
+	now := time.Now()
+
+	var available, rest []*endpoint
+
+	for _, e := range c.endpoints {
+		if e.available(now) {
+			available = append(available, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].score() < available[j].score()
+	})
+
+	return append(available, rest...)
+}
+
+// post sends the body to the endpoint, the boolean reports whether another endpoint should be tried
+func (c *Client) post(e *endpoint, method string, body []byte) ([]byte, bool, error) {
+	start := time.Now()
+
+	resp, err := c.httpClient.Post(e.url, "application/json", bytes.NewBuffer(body))
+
+	if err != nil {
+		e.recordFailure()
+
+		return nil, true, &TransportError{Method: method, Url: e.url, Err: err}
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		e.recordFailure()
+
+		return nil, true, &TransportError{Method: method, Url: e.url, Err: err}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		e.recordFailure()
+
+		return nil, true, &HTTPStatusError{Method: method, StatusCode: resp.StatusCode, Body: truncate(data)}
+	}
+
+	e.recordSuccess(time.Since(start))
+
+	if resp.StatusCode != 200 {
+		return nil, false, &HTTPStatusError{Method: method, StatusCode: resp.StatusCode, Body: truncate(data)}
+	}
+
+	return data, false, nil
+}
+
+func truncate(data []byte) string {
+	if len(data) > 256 {
+		return string(data[:256])
+	}
+
+	return string(data)
+}
+
+// Call sends a JSON-RPC request and decodes its result into result
+func (c *Client) Call(method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(request{
+		ID:      1,
+		JsonRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+
+	if err != nil {
+		return fmt.Errorf("%s: failed to marshal body %w", method, err)
+	}
+
+	var lastErr error
+
+	for _, e := range c.candidates() {
+		data, failover, err := c.post(e, method, body)
+
+		if err != nil {
+			lastErr = err
+
+			if failover {
+				log.Printf("rpc: %s failed on %s, trying next endpoint: %s \n", method, e.url, err)
+				continue
+			}
+
+			return err
+		}
+
+		var resp response
+
+		err = json.Unmarshal(data, &resp)
+
+		if err != nil {
+			return &DecodeError{Method: method, Err: err}
+		}
+
+		if resp.Error != nil {
+			return &RPCError{Method: method, Code: resp.Error.Code, Message: resp.Error.Message, Data: resp.Error.Data}
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		err = json.Unmarshal(resp.Result, result)
+
+		if err != nil {
+			return &DecodeError{Method: method, Err: err}
+		}
+
+		return nil
+	}
+
+	// the last error keeps its type so callers can still tell transport from status failures
+	return lastErr
+}
+
+func (c *Client) checkHealth(e *endpoint) {
+	body, _ := json.Marshal(request{ID: 1, JsonRPC: "2.0", Method: "getHealth"})
+
+	data, _, err := c.post(e, "getHealth", body)
+
+	if err != nil {
+		e.setHealthy(false)
+		log.Printf("CheckHealth: %s is unhealthy: %s \n", e.url, err)
+
+		return
+	}
+
+	var resp response
+	json.Unmarshal(data, &resp)
+
+	// a node that is behind answers with an error instead of "ok"
+	healthy := resp.Error == nil && string(resp.Result) == `"ok"`
+	e.setHealthy(healthy)
+
+	if !healthy {
+		log.Printf("CheckHealth: %s is unhealthy: %s \n", e.url, string(data))
+	}
+}
+
+// CheckHealth periodically probes every endpoint with getHealth
+func (c *Client) CheckHealth() {
+	for {
+		for _, e := range c.endpoints {
+			c.checkHealth(e)
+		}
+
+		for _, s := range c.Stats() {
+			log.Printf("CheckHealth: %s healthy=%t latency=%s failures=%d \n", s.Url, s.Healthy, s.Latency, s.Failures)
+		}
+
+		time.Sleep(c.healthInterval)
+	}
+}
+
+func (c *Client) Stats() []EndpointStats {
+	var stats []EndpointStats
+
+	for _, e := range c.endpoints {
+		stats = append(stats, e.snapshot())
+	}
+
+	return stats
+}
+
+func newClient(c *config.RpcConfig) *Client {
+	client := &Client{
+		httpClient:     &http.Client{Timeout: defaultTimeout},
+		healthInterval: defaultHealthInterval,
+	}
+
+	if c.TimeoutMs > 0 {
+		client.httpClient.Timeout = time.Duration(c.TimeoutMs) * time.Millisecond
+	}
+
+	if c.HealthCheckSeconds > 0 {
+		client.healthInterval = time.Duration(c.HealthCheckSeconds) * time.Second
+	}
+
+	for _, e := range c.Endpoints {
+		client.endpoints = append(client.endpoints, &endpoint{url: e.Url, weight: max(e.Weight, 1), healthy: true})
+	}
+
+	return client
+}
+
+// New creates a client for the configured endpoints, falling back to the helius rpc url
+func New(c *config.RpcConfig, h *config.HeliusConfig) *Client {
+	client := newClient(c)
+
+	if len(client.endpoints) == 0 {
+		url := fmt.Sprintf("%s?api-key=%s", h.RpcUrl, h.ApiKey)
+		client.endpoints = append(client.endpoints, &endpoint{url: url, weight: 1, healthy: true})
+	}
+
+	return client
+}
+
+// NewEndpoint creates a client for a single url that is not an rpc node, e.g. a block engine
+func NewEndpoint(url string, timeoutMs int) *Client {
+	return newClient(&config.RpcConfig{
+		Endpoints: []config.RpcEndpointConfig{{Url: url, Weight: 1}},
+		TimeoutMs: timeoutMs,
+	})
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	baseCooldown = 2 * time.Second
+	maxCooldown  = 1 * time.Minute
+
+	// weight of the latest sample in the latency moving average
+	latencyAlpha = 0.2
+)
+
+type endpoint struct {
+	url    string
+	weight int
+
+	mu            sync.Mutex
+	healthy       bool
+	latency       time.Duration // exponentially weighted moving average
+	failures      int           // consecutive failures
+	cooldownUntil time.Time
+}
+
+func (e *endpoint) recordLatency(d time.Duration) {
+	if e.latency == 0 {
+		e.latency = d
+	} else {
+		e.latency = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(e.latency))
+	}
+}
+
+func (e *endpoint) recordSuccess(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.recordLatency(d)
+	e.failures = 0
+	e.cooldownUntil = time.Time{}
+}
+
+// recordFailure takes the endpoint out of rotation for a cooldown that doubles with every consecutive failure
+func (e *endpoint) recordFailure() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+
+	cooldown := baseCooldown << min(e.failures-1, 5)
+	e.cooldownUntil = time.Now().Add(min(cooldown, maxCooldown))
+}
+
+func (e *endpoint) setHealthy(healthy bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.healthy = healthy
+}
+
+func (e *endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.healthy && now.After(e.cooldownUntil)
+}
+
+// score ranks endpoints, lower is better: latency divided by weight
+func (e *endpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return float64(e.latency) / float64(max(e.weight, 1))
+}
+
+func (e *endpoint) snapshot() EndpointStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return EndpointStats{
+		Url:           e.url,
+		Weight:        e.weight,
+		Healthy:       e.healthy,
+		Latency:       e.latency,
+		Failures:      e.failures,
+		CooldownUntil: e.cooldownUntil,
+	}
+}
+
+type EndpointStats struct {
+	Url           string
+	Weight        int
+	Healthy       bool
+	Latency       time.Duration
+	Failures      int
+	CooldownUntil time.Time
+}
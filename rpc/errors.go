@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSON-RPC error codes returned by Solana nodes
+const (
+	CodeSendTransactionPreflightFailure = -32002 // the reason is in the message, see IsBlockhashNotFound
+	CodeNodeUnhealthy                   = -32005
+	CodeTransactionPrecompileFail       = -32008
+)
+
+// TransportError means the request never got a response: dns, connection refused, timeout...
+type TransportError struct {
+	Method string
+	Url    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s: request failed: %s", e.Method, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError means the endpoint answered with a non 200 status
+type HTTPStatusError struct {
+	Method     string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status code %d %s", e.Method, e.StatusCode, e.Body)
+}
+
+// RPCError is the JSON-RPC error object of a response
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+	Data    json.RawMessage
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: rpc error %d %s", e.Method, e.Code, e.Message)
+}
+
+type simulationData struct {
+	Err           interface{} `json:"err"`
+	Logs          []string    `json:"logs"`
+	UnitsConsumed uint64      `json:"unitsConsumed"`
+}
+
+// IsPreflightFailure reports whether sendTransaction rejected the transaction because its simulation failed
+func (e *RPCError) IsPreflightFailure() bool {
+	return e.Code == CodeSendTransactionPreflightFailure && strings.Contains(e.Message, "simulation failed")
+}
+
+// SimulationLogs returns the program logs of a failed preflight simulation
+func (e *RPCError) SimulationLogs() []string {
+	var data simulationData
+
+	json.Unmarshal(e.Data, &data)
+
+	return data.Logs
+}
+
+// DecodeError means the response body could not be decoded
+type DecodeError struct {
+	Method string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: failed to decode response: %s", e.Method, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsBlockhashNotFound reports whether a transaction was rejected because its blockhash expired,
+// in which case it has to be rebuilt with a fresh blockhash
+func IsBlockhashNotFound(err error) bool {
+	var rpcErr *RPCError
+
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	return strings.Contains(rpcErr.Message, "Blockhash not found") || strings.Contains(string(rpcErr.Data), "BlockhashNotFound")
+}
+
+// IsUnavailable reports whether the error comes from the transport or the endpoint rather than the request itself
+func IsUnavailable(err error) bool {
+	var transportErr *TransportError
+	var statusErr *HTTPStatusError
+
+	return errors.As(err, &transportErr) || errors.As(err, &statusErr)
+}
@@ -0,0 +1,274 @@
+package rugcheck
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"solana-bot/config"
+	"solana-bot/db"
+	"solana-bot/helius"
+	"solana-bot/mints"
+	"solana-bot/utils"
+	"strconv"
+)
+
+const (
+	incinerator = "1nc1nerator11111111111111111111111111111111"
+
+	// vault owner of raydium amm v4 pools
+	raydiumAuthority = "5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1"
+
+	defaultMaxTopHoldersPct   = 30
+	defaultMinLpLockedPct     = 90
+	defaultMaxCreatorLaunches = 3
+	topHolders                = 10
+)
+
+// Checker scores how likely a token is to be rugged from its mint, pool and creator
+type Checker struct {
+	c     *config.RiskConfig
+	h     *helius.HttpClient
+	mints *mints.Service
+	db    *db.SqlClient
+}
+
+type check struct {
+	weight float64
+	run    func(t db.TokenEntity, m *db.MintEntity) db.RiskCheck
+}
+
+// Score runs every check against the token, stores the result on the token row and returns the score
+func (c *Checker) Score(t db.TokenEntity) (float64, []db.RiskCheck, error) {
+
+	// authorities can be revoked after launch, always read the current mint account
+	m, err := c.mints.Refresh(t.ContractAddress)
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("Score: %w", err)
+	}
+
+	checks := []check{
+		{25, c.checkMintAuthority},
+		{20, c.checkFreezeAuthority},
+		{20, c.checkLpLocked},
+		{15, c.checkTopHolders},
+		{10, c.checkToken2022},
+		{10, c.checkCreator},
+	}
+
+	var results []db.RiskCheck
+	var total, passed float64
+
+	for _, ch := range checks {
+		r := ch.run(t, m)
+		r.Weight = ch.weight
+
+		if !r.Skipped {
+			total += r.Weight
+
+			if r.Passed {
+				passed += r.Weight
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	score := 0.0
+
+	if total > 0 {
+		score = passed / total * 100
+	}
+
+	log.Printf("Score: Token %s scored %.0f \n", t.ContractAddress, score)
+
+	c.db.UpdateTokenRisk(t.ContractAddress, score, utils.ToString(results))
+
+	return score, results, nil
+}
+
+func (c *Checker) checkMintAuthority(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	if m.MintAuthority != nil {
+		return db.RiskCheck{Name: "mintAuthority", Detail: "mint authority " + *m.MintAuthority + " can mint more tokens"}
+	}
+
+	return db.RiskCheck{Name: "mintAuthority", Passed: true, Detail: "revoked"}
+}
+
+func (c *Checker) checkFreezeAuthority(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	if m.FreezeAuthority != nil {
+		return db.RiskCheck{Name: "freezeAuthority", Detail: "freeze authority " + *m.FreezeAuthority + " can freeze holders"}
+	}
+
+	return db.RiskCheck{Name: "freezeAuthority", Passed: true, Detail: "revoked"}
+}
+
+// checkLpLocked computes the share of the LP supply that was burned or sits with a locker
+func (c *Checker) checkLpLocked(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	r := db.RiskCheck{Name: "lpLocked"}
+
+	if t.LpMint == nil {
+		r.Skipped, r.Detail = true, "no lp mint"
+		return r
+	}
+
+	lp, err := c.mints.Refresh(*t.LpMint)
+
+	if err != nil {
+		r.Skipped, r.Detail = true, err.Error()
+		return r
+	}
+
+	// burning the LP tokens reduces the supply
+	if lp.Supply == 0 {
+		r.Passed, r.Detail = true, "lp supply burned"
+		return r
+	}
+
+	lockers := append([]string{incinerator}, c.c.LpLockers...)
+
+	held, err := c.heldBy(*t.LpMint, func(owner string) bool { return slices.Contains(lockers, owner) })
+
+	if err != nil {
+		r.Skipped, r.Detail = true, err.Error()
+		return r
+	}
+
+	pct := float64(held) / float64(lp.Supply) * 100
+	minPct := orDefault(c.c.MinLpLockedPct, defaultMinLpLockedPct)
+
+	r.Passed = pct >= minPct
+	r.Detail = fmt.Sprintf("%.2f%% of the lp supply is burned or locked (min %g%%)", pct, minPct)
+
+	return r
+}
+
+// checkTopHolders computes the share of the supply held by the largest holders, leaving out the pool
+func (c *Checker) checkTopHolders(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	r := db.RiskCheck{Name: "topHolders"}
+
+	if m.Supply == 0 {
+		r.Skipped, r.Detail = true, "no supply"
+		return r
+	}
+
+	excluded := append([]string{raydiumAuthority}, c.c.ExcludedHolders...)
+
+	if t.PoolAddress != nil {
+		excluded = append(excluded, *t.PoolAddress)
+	}
+
+	count := 0
+	held, err := c.heldBy(t.ContractAddress, func(owner string) bool {
+		if slices.Contains(excluded, owner) || count >= topHolders {
+			return false
+		}
+
+		count++
+
+		return true
+	})
+
+	if err != nil {
+		r.Skipped, r.Detail = true, err.Error()
+		return r
+	}
+
+	pct := float64(held) / float64(m.Supply) * 100
+	maxPct := orDefault(c.c.MaxTopHoldersPct, defaultMaxTopHoldersPct)
+
+	r.Passed = pct <= maxPct
+	r.Detail = fmt.Sprintf("top %d holders own %.2f%% of the supply (max %g%%)", topHolders, pct, maxPct)
+
+	return r
+}
+
+// checkToken2022 flags transfer fees above the limit and transfer hooks, which can block or tax selling
+func (c *Checker) checkToken2022(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	r := db.RiskCheck{Name: "token2022", Passed: true, Detail: "no risky extensions"}
+
+	if m.TransferFeeBps != nil && *m.TransferFeeBps > c.c.MaxTransferFeeBps {
+		r.Passed = false
+		r.Detail = fmt.Sprintf("transfer fee of %d bps (max %d)", *m.TransferFeeBps, c.c.MaxTransferFeeBps)
+	}
+
+	if m.TransferHookProgram != nil {
+		r.Passed = false
+		r.Detail = "transfer hook program " + *m.TransferHookProgram
+	}
+
+	return r
+}
+
+// checkCreator looks at the launches of the creator wallet that were detected before
+func (c *Checker) checkCreator(t db.TokenEntity, m *db.MintEntity) db.RiskCheck {
+	r := db.RiskCheck{Name: "creator"}
+
+	if t.Creator == nil {
+		r.Skipped, r.Detail = true, "unknown creator"
+		return r
+	}
+
+	launches := c.db.CountTokensByCreator(*t.Creator, t.ContractAddress)
+	maxLaunches := c.c.MaxCreatorLaunches
+
+	if maxLaunches == 0 {
+		maxLaunches = defaultMaxCreatorLaunches
+	}
+
+	r.Passed = launches <= maxLaunches
+	r.Detail = fmt.Sprintf("creator %s launched %d other tokens (max %d)", *t.Creator, launches, maxLaunches)
+
+	return r
+}
+
+// heldBy sums the balances of the largest token accounts of a mint whose owner matches
+func (c *Checker) heldBy(mint string, match func(owner string) bool) (uint64, error) {
+	accounts, err := c.h.GetTokenLargestAccounts(mint)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(accounts) == 0 {
+		return 0, nil
+	}
+
+	var addresses []string
+
+	for _, a := range accounts {
+		addresses = append(addresses, a.Address)
+	}
+
+	owners, err := c.h.GetTokenAccountOwners(addresses)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var held uint64
+
+	// largest first, so a match limited to n owners sees the n largest
+	for _, a := range accounts {
+		if !match(owners[a.Address]) {
+			continue
+		}
+
+		amount, _ := strconv.ParseUint(a.Amount, 10, 64)
+		held += amount
+	}
+
+	return held, nil
+}
+
+func orDefault(v, def float64) float64 {
+	if v > 0 {
+		return v
+	}
+
+	return def
+}
+
+func New(c *config.RiskConfig, h *helius.HttpClient, m *mints.Service, sc *db.SqlClient) *Checker {
+	return &Checker{c: c, h: h, mints: m, db: sc}
+}
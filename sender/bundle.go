@@ -0,0 +1,160 @@
+package sender
+
+import (
+	"fmt"
+	"log"
+	"solana-bot/config"
+	"solana-bot/rpc"
+	"solana-bot/utils"
+	"solana-bot/wallet"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+const (
+	defaultBundleStatusTimeout = 10 * time.Second
+	bundleStatusPoll           = 500 * time.Millisecond
+)
+
+type BundleStatus struct {
+	BundleId           string      `json:"bundle_id"`
+	Transactions       []string    `json:"transactions"`
+	Slot               uint64      `json:"slot"`
+	ConfirmationStatus string      `json:"confirmation_status"`
+	Err                interface{} `json:"err"`
+}
+
+// Failed reports whether the bundle was rejected, a landed bundle reports an err of {"Ok": null}
+func (b *BundleStatus) Failed() bool {
+	if b.Err == nil {
+		return false
+	}
+
+	result, ok := b.Err.(map[string]interface{})
+
+	if !ok {
+		return true
+	}
+
+	_, landed := result["Ok"]
+
+	return !landed
+}
+
+type GetBundleStatusesResult struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value []*BundleStatus `json:"value"`
+}
+
+// Bundle sends a transaction to a block engine together with a tip transfer signed with the same
+// blockhash, the bundle lands atomically or not at all
+type Bundle struct {
+	c   *config.BundleConfig
+	w   *wallet.Client
+	rpc *rpc.Client
+}
+
+func (s *Bundle) Send(signedTx string) (string, error) {
+	data, err := base58.Decode(signedTx)
+
+	if err != nil {
+		return "", fmt.Errorf("Bundle: failed to decode transaction: %w", err)
+	}
+
+	tx, err := solana.TransactionFromBytes(data)
+
+	if err != nil {
+		return "", fmt.Errorf("Bundle: failed to decode transaction: %w", err)
+	}
+
+	if len(tx.Signatures) == 0 {
+		return "", fmt.Errorf("Bundle: transaction is not signed")
+	}
+
+	tip, err := s.w.CreateSignedTransfer(s.c.TipAccount, s.c.TipLamports, tx.Message.RecentBlockhash)
+
+	if err != nil {
+		return "", fmt.Errorf("Bundle: failed to create tip: %w", err)
+	}
+
+	bundleId, err := s.SendBundle([]string{signedTx, tip})
+
+	if err != nil {
+		return "", err
+	}
+
+	signature := tx.Signatures[0].String()
+
+	log.Printf("Bundle: Sent bundle %s with %s and a tip of %d lamports \n", bundleId, signature, s.c.TipLamports)
+
+	status, err := s.awaitBundle(bundleId)
+
+	if err != nil {
+		log.Println("Bundle:", err)
+
+		return signature, nil
+	}
+
+	// bundles land atomically, a failed one did not send the transaction
+	if status.Failed() {
+		return "", fmt.Errorf("Bundle: bundle %s with %s failed: %s", bundleId, signature, utils.ToString(status.Err))
+	}
+
+	return signature, nil
+}
+
+// awaitBundle waits a short while for the block engine to report the bundle. When it is not reported
+// in time whether the transaction landed is decided by its signature status.
+func (s *Bundle) awaitBundle(bundleId string) (*BundleStatus, error) {
+	timeout := defaultBundleStatusTimeout
+
+	if s.c.StatusTimeoutSeconds > 0 {
+		timeout = time.Duration(s.c.StatusTimeoutSeconds) * time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		statuses, err := s.GetBundleStatuses([]string{bundleId})
+
+		if err != nil {
+			log.Println("awaitBundle:", err)
+		} else if len(statuses) > 0 && statuses[0] != nil {
+			log.Printf("awaitBundle: Bundle %s is %s in slot %d \n", bundleId, statuses[0].ConfirmationStatus, statuses[0].Slot)
+
+			return statuses[0], nil
+		}
+
+		time.Sleep(bundleStatusPoll)
+	}
+
+	return nil, fmt.Errorf("awaitBundle: bundle %s not reported after %s", bundleId, timeout)
+}
+
+// SendBundle submits base58 encoded transactions as one bundle and returns the bundle id
+func (s *Bundle) SendBundle(txs []string) (string, error) {
+	var bundleId string
+
+	err := s.rpc.Call("sendBundle", []interface{}{txs}, &bundleId)
+
+	return bundleId, err
+}
+
+// GetBundleStatuses returns one status per bundle id, nil while the bundle has not landed
+func (s *Bundle) GetBundleStatuses(bundleIds []string) ([]*BundleStatus, error) {
+	var result GetBundleStatusesResult
+
+	err := s.rpc.Call("getBundleStatuses", []interface{}{bundleIds}, &result)
+
+	return result.Value, err
+}
+
+// NewBundle creates a sender for the configured block engine url, any JSON-RPC server implementing
+// sendBundle and getBundleStatuses works, e.g. a local stand-in
+func NewBundle(c *config.BundleConfig, w *wallet.Client) *Bundle {
+	return &Bundle{c: c, w: w, rpc: rpc.NewEndpoint(c.Url, c.TimeoutMs)}
+}
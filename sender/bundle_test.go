@@ -0,0 +1,153 @@
+package sender
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"solana-bot/config"
+	"solana-bot/wallet"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// blockEngine is a stand-in for the sendBundle and getBundleStatuses methods of a block engine
+type blockEngine struct {
+	mu      sync.Mutex
+	status  json.RawMessage
+	bundles [][]string
+}
+
+func (b *blockEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result interface{}
+
+	switch req.Method {
+	case "sendBundle":
+		var txs []string
+		json.Unmarshal(req.Params[0], &txs)
+		b.bundles = append(b.bundles, txs)
+
+		result = "bundle-1"
+	case "getBundleStatuses":
+		result = map[string]interface{}{
+			"context": map[string]uint64{"slot": 100},
+			"value":   []json.RawMessage{b.status},
+		}
+	default:
+		http.Error(w, "unknown method "+req.Method, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func newTestBundle(t *testing.T, status string) (*Bundle, *blockEngine, *wallet.Client) {
+	t.Helper()
+
+	key := solana.NewWallet().PrivateKey
+	w := wallet.New(&config.WalletConfig{PrivKey: key.String(), Pubkey: key.PublicKey().String()}, nil)
+
+	engine := &blockEngine{status: json.RawMessage(status)}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	b := NewBundle(&config.BundleConfig{
+		Url:                  server.URL,
+		TipAccount:           solana.NewWallet().PublicKey().String(),
+		TipLamports:          1000,
+		StatusTimeoutSeconds: 1,
+	}, w)
+
+	return b, engine, w
+}
+
+// signedSwap stands in for a signed swap transaction
+func signedSwap(t *testing.T, w *wallet.Client) string {
+	t.Helper()
+
+	tx, err := w.CreateSignedTransfer(solana.NewWallet().PublicKey().String(), 1, solana.Hash{1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestBundleSendLanded(t *testing.T) {
+	b, engine, w := newTestBundle(t, `{"bundle_id":"bundle-1","transactions":[],"slot":99,"confirmation_status":"confirmed","err":{"Ok":null}}`)
+
+	tx := signedSwap(t, w)
+	signature, err := b.Send(tx)
+
+	if err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	parsed, _ := solana.TransactionFromBase58(tx)
+
+	if signature != parsed.Signatures[0].String() {
+		t.Errorf("signature = %s, want %s", signature, parsed.Signatures[0])
+	}
+
+	if len(engine.bundles) != 1 || len(engine.bundles[0]) != 2 || engine.bundles[0][0] != tx {
+		t.Fatalf("bundle = %v, want the swap followed by the tip", engine.bundles)
+	}
+
+	tip, err := solana.TransactionFromBase58(engine.bundles[0][1])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tip.Message.RecentBlockhash != parsed.Message.RecentBlockhash {
+		t.Errorf("tip blockhash = %s, want %s", tip.Message.RecentBlockhash, parsed.Message.RecentBlockhash)
+	}
+}
+
+func TestBundleSendFailed(t *testing.T) {
+	b, _, w := newTestBundle(t, `{"bundle_id":"bundle-1","transactions":[],"slot":99,"confirmation_status":"processed","err":{"Err":"BundleRejected"}}`)
+
+	signature, err := b.Send(signedSwap(t, w))
+
+	if err == nil {
+		t.Fatalf("Send = %s, want an error for a failed bundle", signature)
+	}
+}
+
+func TestBundleSendTimeout(t *testing.T) {
+	b, _, w := newTestBundle(t, `null`)
+
+	tx := signedSwap(t, w)
+	signature, err := b.Send(tx)
+
+	// the signature is still tracked, the bundle may land after the status timeout
+	if err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	if len(signature) == 0 {
+		t.Error("Send returned no signature")
+	}
+
+	status, err := b.awaitBundle("bundle-1")
+
+	if err == nil || status != nil {
+		t.Errorf("awaitBundle = %v, %v, want a timeout", status, err)
+	}
+}
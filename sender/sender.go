@@ -0,0 +1,46 @@
+package sender
+
+import (
+	"log"
+	"solana-bot/config"
+	"solana-bot/helius"
+	"solana-bot/wallet"
+)
+
+const (
+	SenderRpc    = "rpc"
+	SenderBundle = "bundle"
+)
+
+// TxSender submits a signed, base58 encoded transaction and returns its signature, which the
+// confirmation loop tracks with getSignatureStatuses whichever way the transaction was sent
+type TxSender interface {
+	Send(signedTx string) (string, error)
+}
+
+// Rpc sends transactions with sendTransaction through the rpc endpoints
+type Rpc struct {
+	h *helius.HttpClient
+}
+
+func (s *Rpc) Send(signedTx string) (string, error) {
+	return s.h.SendTransaction(signedTx)
+}
+
+func NewRpc(h *helius.HttpClient) *Rpc {
+	return &Rpc{h: h}
+}
+
+// New returns the sender selected by trader.sender, rpc by default
+func New(c *config.TraderConfig, h *helius.HttpClient, w *wallet.Client) TxSender {
+	switch c.Sender {
+	case "", SenderRpc:
+		return NewRpc(h)
+	case SenderBundle:
+		return NewBundle(&c.Bundle, w)
+	}
+
+	log.Printf("sender.New: Unknown sender %s, using %s \n", c.Sender, SenderRpc)
+
+	return NewRpc(h)
+}
@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"fmt"
+	"slices"
+	"solana-bot/config"
+	"solana-bot/db"
+	"time"
+)
+
+// EvaluateEntry checks a token and its latest market data against the filters of an entry strategy.
+// It returns the first filter that did not pass, or a summary of the match.
+func EvaluateEntry(s config.EntryStrategyConfig, t db.TokenEntity, m *db.MarketDataEntity, now time.Time) (string, bool) {
+
+	if len(s.Detectors) > 0 && (t.Detector == nil || !slices.Contains(s.Detectors, *t.Detector)) {
+		return "detector not included", false
+	}
+
+	if s.MinRiskScore > 0 && (t.RiskScore == nil || *t.RiskScore < s.MinRiskScore) {
+		return "risk score below minimum or not scored yet", false
+	}
+
+	// the pair creation is only known once dexscreener indexed the token
+	createdAt := t.CreatedAt
+
+	if t.PairCreatedAt != nil {
+		createdAt = *t.PairCreatedAt
+	}
+
+	age := now.Sub(createdAt)
+
+	if s.MinAgeMinutes > 0 && age < time.Duration(s.MinAgeMinutes)*time.Minute {
+		return fmt.Sprintf("age %s below minimum", age.Round(time.Second)), false
+	}
+
+	if s.MaxAgeMinutes > 0 && age > time.Duration(s.MaxAgeMinutes)*time.Minute {
+		return fmt.Sprintf("age %s above maximum", age.Round(time.Second)), false
+	}
+
+	if m == nil {
+		return "no market data", false
+	}
+
+	if s.MinLiquidityUsd > 0 && m.LiquidityUsd < s.MinLiquidityUsd {
+		return fmt.Sprintf("liquidity $%.0f below minimum", m.LiquidityUsd), false
+	}
+
+	if s.MinMarketCap > 0 && m.MarketCap < s.MinMarketCap {
+		return fmt.Sprintf("market cap $%.0f below minimum", m.MarketCap), false
+	}
+
+	if s.MaxMarketCap > 0 && m.MarketCap > s.MaxMarketCap {
+		return fmt.Sprintf("market cap $%.0f above maximum", m.MarketCap), false
+	}
+
+	ratio := BuySellRatio(m)
+
+	if s.MinBuySellRatio > 0 && ratio < s.MinBuySellRatio {
+		return fmt.Sprintf("buy/sell ratio %.2f below minimum", ratio), false
+	}
+
+	return fmt.Sprintf("liquidity $%.0f, market cap $%.0f, buy/sell ratio %.2f, age %s",
+		m.LiquidityUsd, m.MarketCap, ratio, age.Round(time.Second)), true
+}
+
+// BuySellRatio returns the 5 minute buys per sell, all buys count when there were no sells
+func BuySellRatio(m *db.MarketDataEntity) float64 {
+	if m.BuysM5 == nil || m.SellsM5 == nil {
+		return 0
+	}
+
+	return float64(*m.BuysM5) / float64(max(*m.SellsM5, 1))
+}
@@ -0,0 +1,79 @@
+package strategy
+
+import (
+	"fmt"
+	"solana-bot/db"
+)
+
+// EvaluateExit checks the take profit and stop loss of rules against the current price.
+// Prices are in SOL per token, a zero threshold disables that side of the rule.
+func EvaluateExit(rules db.SwapRules, entryPrice, price float64) (string, bool) {
+
+	if price <= 0 {
+		return "", false
+	}
+
+	if rules.Type == db.RulesTypePrice {
+		if rules.TakeProfit > 0 && price >= float64(rules.TakeProfit) {
+			return fmt.Sprintf("take profit: price %g >= %g", price, rules.TakeProfit), true
+		}
+
+		if rules.StopLoss > 0 && price <= float64(rules.StopLoss) {
+			return fmt.Sprintf("stop loss: price %g <= %g", price, rules.StopLoss), true
+		}
+
+		return "", false
+	}
+
+	if entryPrice <= 0 {
+		return "", false
+	}
+
+	change := (price - entryPrice) / entryPrice * 100
+
+	if rules.TakeProfit > 0 && change >= float64(rules.TakeProfit) {
+		return fmt.Sprintf("take profit: price %g is %+.2f%% from entry %g (target +%g%%)", price, change, entryPrice, rules.TakeProfit), true
+	}
+
+	if rules.StopLoss > 0 && change <= -float64(rules.StopLoss) {
+		return fmt.Sprintf("stop loss: price %g is %+.2f%% from entry %g (limit -%g%%)", price, change, entryPrice, rules.StopLoss), true
+	}
+
+	return "", false
+}
+
+// EvaluateLadder returns the index of the first ladder step that is reached at price and not done yet
+func EvaluateLadder(rules db.SwapRules, entryPrice, price float64, done map[int]bool) (int, string, bool) {
+
+	if entryPrice <= 0 || price <= 0 {
+		return 0, "", false
+	}
+
+	for i, step := range rules.Ladder {
+		if done[i] || step.Multiple <= 0 || step.Fraction <= 0 {
+			continue
+		}
+
+		if price >= entryPrice*float64(step.Multiple) {
+			return i, fmt.Sprintf("ladder step %d: price %g >= %gx entry %g, selling %g%%", i+1, price, step.Multiple, entryPrice, step.Fraction*100), true
+		}
+	}
+
+	return 0, "", false
+}
+
+// EvaluateTrailingStop checks the price against the highest price seen since entry
+func EvaluateTrailingStop(rules db.SwapRules, peakPrice, price float64) (string, bool) {
+
+	if rules.TrailingStop <= 0 || peakPrice <= 0 || price <= 0 {
+		return "", false
+	}
+
+	drop := (peakPrice - price) / peakPrice * 100
+
+	if drop >= float64(rules.TrailingStop) {
+		return fmt.Sprintf("trailing stop: price %g is %.2f%% below peak %g (limit %g%%)", price, drop, peakPrice, rules.TrailingStop), true
+	}
+
+	return "", false
+}
@@ -1,12 +1,14 @@
 package wallet
 
 import (
+	"fmt"
 	"log"
 	"solana-bot/config"
 	"solana-bot/helius"
 	"strconv"
 
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
 	"github.com/mr-tron/base58"
 )
 
@@ -40,65 +42,124 @@ func New(c *config.WalletConfig, h *helius.HttpClient) *Client {
 	}
 }
 
-func (w *Client) GetBalance() int {
-	balLamport := w.h.GetBalance(w.PublicKey)
+func (w *Client) GetBalance() (int, error) {
+	balLamport, err := w.h.GetBalance(w.PublicKey)
+
+	if err != nil {
+		return 0, fmt.Errorf("GetBalance: %w", err)
+	}
 
 	balSol := float32(balLamport) / float32(LAMPORT)
 
 	log.Printf("Bal readable sol: %f", balSol)
 
-	return balLamport
+	return balLamport, nil
 
 }
 
-func (w *Client) GetTokenBalance(mint string) int {
+// TokenHolding is the wallet's balance of a mint in atomic units, with the mint decimals
+type TokenHolding struct {
+	Amount   int
+	Decimals int
+}
 
-	result := w.h.GetTokenAccountsByOwner(w.PublicKey, mint)
+func (w *Client) GetTokenHolding(mint string) (TokenHolding, error) {
 
-	if result == nil {
-		return 0
-	}
+	var holding TokenHolding
 
-	amount := 0
+	result, err := w.h.GetTokenAccountsByOwner(w.PublicKey, mint)
 
-	for _, v := range result.Result.Value {
+	if err != nil {
+		return holding, fmt.Errorf("GetTokenHolding: %w", err)
+	}
+
+	for _, v := range result.Value {
 		if v.Account.Data.Parsed.Info.Mint == mint {
 			val, err := strconv.Atoi(v.Account.Data.Parsed.Info.TokenAmount.Amount)
 
 			if err != nil {
-				log.Println("Err converting tokenAmount to integer", err)
-			} else {
-				amount = val
+				return holding, fmt.Errorf("GetTokenHolding: failed to convert tokenAmount to integer %w", err)
 			}
+
+			holding.Amount = val
+			holding.Decimals = v.Account.Data.Parsed.Info.TokenAmount.Decimals
 		}
 	}
 
-	return amount
+	return holding, nil
 
 }
 
-func (w *Client) CreateSignedTxMessage(message string) string {
+func (w *Client) GetTokenBalance(mint string) (int, error) {
+	holding, err := w.GetTokenHolding(mint)
+
+	return holding.Amount, err
+}
+
+// CreateSignedTxMessage signs a base64 transaction and returns it base58 encoded for sendTransaction,
+// together with its signature which is known before the transaction is sent
+func (w *Client) CreateSignedTxMessage(message string) (string, string, error) {
 
 	tx, err := solana.TransactionFromBase64(message)
 
 	if err != nil {
-		log.Println("CreateTx: TransactionFromBase64", err)
-
-		return ""
+		return "", "", fmt.Errorf("CreateTx: TransactionFromBase64 %w", err)
 	}
 
-	tx.Sign(func(p solana.PublicKey) *solana.PrivateKey {
+	_, err = tx.Sign(func(p solana.PublicKey) *solana.PrivateKey {
 		return &w.privKey
 	})
 
+	if err != nil {
+		return "", "", fmt.Errorf("CreateTx: Sign %w", err)
+	}
+
 	txBytes, err := tx.MarshalBinary()
 
 	if err != nil {
-		log.Println("CreateTx: MarshalBinary", err)
+		return "", "", fmt.Errorf("CreateTx: MarshalBinary %w", err)
+	}
+
+	return base58.Encode(txBytes), tx.Signatures[0].String(), nil
+
+}
+
+// CreateSignedTransfer builds and signs a SOL transfer from the wallet, base58 encoded for
+// sendTransaction or a bundle. The blockhash is usually the one of the transaction it goes with.
+func (w *Client) CreateSignedTransfer(to string, lamports uint64, recentBlockhash solana.Hash) (string, error) {
+
+	recipient, err := solana.PublicKeyFromBase58(to)
+
+	if err != nil {
+		return "", fmt.Errorf("CreateTransfer: invalid recipient %s: %w", to, err)
+	}
+
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{
+			system.NewTransferInstruction(lamports, w.privKey.PublicKey(), recipient).Build(),
+		},
+		recentBlockhash,
+		solana.TransactionPayer(w.privKey.PublicKey()),
+	)
+
+	if err != nil {
+		return "", fmt.Errorf("CreateTransfer: NewTransaction %w", err)
+	}
 
-		return ""
+	_, err = tx.Sign(func(p solana.PublicKey) *solana.PrivateKey {
+		return &w.privKey
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("CreateTransfer: Sign %w", err)
+	}
+
+	txBytes, err := tx.MarshalBinary()
+
+	if err != nil {
+		return "", fmt.Errorf("CreateTransfer: MarshalBinary %w", err)
 	}
 
-	return base58.Encode(txBytes)
+	return base58.Encode(txBytes), nil
 
 }